@@ -0,0 +1,222 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Exit codes returned to the calling shell. They are listed in the generated help.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitConfig      = 3
+	exitUnavailable = 4
+	exitProxy       = 5
+	exitUser        = 6
+	exitToken       = 7
+)
+
+var exitCodes = []struct {
+	code int
+	desc string
+}{
+	{exitOK, "success"},
+	{exitFailure, "unexpected failure"},
+	{exitUsage, "invalid command line"},
+	{exitConfig, "configuration could not be loaded or is invalid"},
+	{exitUnavailable, "reverse proxy or secret service is not reachable"},
+	{exitProxy, "reverse proxy initialization, reset or certificate upload failed"},
+	{exitUser, "account or group management failed"},
+	{exitToken, "access token could not be issued or saved"},
+}
+
+// runFunc runs a command with the positional arguments left after its options.
+type runFunc func(env *environment, args []string) error
+
+// command is a node of the command tree. setup registers the options of the
+// command on its flag set and returns the function running it; commands that
+// only group subcommands leave it nil.
+type command struct {
+	name        string
+	args        string
+	summary     string
+	setup       func(fs *flag.FlagSet) runFunc
+	subcommands []*command
+}
+
+// exitError carries the exit code a failed command should end the process with.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// withCode attaches an exit code to err unless it already carries one.
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exitError); ok {
+		return err
+	}
+	return &exitError{code, err}
+}
+
+func usageError(format string, a ...interface{}) error {
+	return &exitError{exitUsage, fmt.Errorf(format, a...)}
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	return exitFailure
+}
+
+func (c *command) find(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// resolve walks args down the command tree and returns the path of command names,
+// the selected command and the arguments left for it.
+func resolve(root *command, args []string) ([]string, *command, []string) {
+	path := []string{}
+	cmd := root
+	for len(args) > 0 {
+		sub := cmd.find(args[0])
+		if sub == nil {
+			break
+		}
+		path = append(path, sub.name)
+		cmd = sub
+		args = args[1:]
+	}
+	return path, cmd, args
+}
+
+// parseArgs parses the options of a command, allowing them to appear before,
+// between or after its positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet creates the flag set of a command with parse errors reported to
+// the caller instead of ending the process.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// printUsage writes the help of cmd, generated from the command tree and the
+// options registered on fs.
+func printUsage(w io.Writer, prog string, path []string, cmd *command, fs *flag.FlagSet, globals *flag.FlagSet) {
+	name := strings.TrimSpace(fmt.Sprintf("%s %s", prog, strings.Join(path, " ")))
+	line := fmt.Sprintf("Usage: %s", name)
+	if len(path) == 0 {
+		line += " [global options]"
+	}
+	if len(cmd.subcommands) > 0 {
+		line += " <command>"
+	}
+	if hasFlags(fs) {
+		line += " [options]"
+	}
+	if cmd.args != "" {
+		line += " " + cmd.args
+	}
+	fmt.Fprintln(w, line)
+	if cmd.summary != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.summary)
+	}
+
+	if len(cmd.subcommands) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(w, "  %-12s %s\n", sub.name, sub.summary)
+		}
+	}
+
+	if hasFlags(fs) {
+		fmt.Fprintln(w, "\nOptions:")
+		printFlags(w, fs)
+	}
+	if hasFlags(globals) {
+		fmt.Fprintln(w, "\nGlobal options:")
+		printFlags(w, globals)
+	}
+
+	if len(path) == 0 {
+		fmt.Fprintln(w, "\nExit codes:")
+		for _, e := range exitCodes {
+			fmt.Fprintf(w, "  %-12d %s\n", e.code, e.desc)
+		}
+		fmt.Fprintf(w, "\nRun '%s <command> --help' for the options of a command.\n", prog)
+	}
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	if fs == nil {
+		return false
+	}
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		opt := "--" + f.Name
+		if name != "" {
+			opt = fmt.Sprintf("%s=<%s>", opt, name)
+		}
+		if f.DefValue != "" && f.DefValue != "false" {
+			usage = fmt.Sprintf("%s (default: %s)", usage, f.DefValue)
+		}
+		fmt.Fprintf(w, "  %-30s %s\n", opt, usage)
+	})
+}
@@ -0,0 +1,700 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	worker "github.com/edgexfoundry/security-api-gateway/internal/pkg/edgexproxy"
+)
+
+// environment holds the global options and the clients built from the configuration.
+type environment struct {
+	configFile         string
+	insecureSkipVerify bool
+	useConsul          bool
+	plan               bool
+	out                io.Writer
+
+	connect     worker.Requestor
+	recorder    *worker.PlanRecorder
+	service     *worker.Service
+	consumerCfg worker.ConsumerConfig
+	validate    func() error
+}
+
+func (env *environment) load() error {
+	config, err := worker.LoadTomlConfig(env.configFile)
+	if err != nil {
+		e := fmt.Errorf("failed to retrieve config data from %s with error %s", env.configFile, err.Error())
+		return withCode(exitConfig, e)
+	}
+
+	if env.useConsul {
+		lc.Info("retrieving config data from Consul")
+	}
+
+	if env.insecureSkipVerify {
+		fmt.Fprintln(os.Stderr, "WARNING: TLS verification is turned off, the secret service and the reverse proxy are not authenticated")
+	}
+	tlsConfig, err := worker.NewTLSConfig(config.GetCACertPath(), env.insecureSkipVerify)
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	proxySSL := net.JoinHostPort(config.GetProxyServerName(), config.GetProxyApplicationPortSSL())
+	serverNames := map[string]string{}
+	if snis := splitList(config.GetSecretSvcSNIS()); len(snis) > 0 {
+		serverNames[proxySSL] = snis[0]
+	}
+	client := worker.NewHTTPClient(tlsConfig, serverNames)
+	er := &worker.EdgeXRequestor{ProxyBaseURL: config.GetProxyBaseURL(), SecretSvcBaseURL: config.GetSecretSvcBaseURL(), Client: client}
+	env.connect = er
+	if env.plan {
+		env.recorder = &worker.PlanRecorder{Connect: er}
+		env.connect = env.recorder
+	}
+	env.service = &worker.Service{Connect: env.connect, CertCfg: config, ServiceCfg: config}
+	env.consumerCfg = config
+	env.validate = config.Validate
+	return nil
+}
+
+func (env *environment) checkProxy() error {
+	return withCode(exitUnavailable, env.service.CheckProxyServiceStatus())
+}
+
+func (env *environment) consumer(name string) *worker.Consumer {
+	return &worker.Consumer{Name: name, Connect: env.connect, Cfg: env.consumerCfg}
+}
+
+// expectArgs checks the number of positional arguments given to a command.
+func expectArgs(args []string, n int) error {
+	if len(args) != n {
+		return usageError("expected %d argument(s), got %d", n, len(args))
+	}
+	return nil
+}
+
+// expectMinArgs checks that a command got at least n positional arguments.
+func expectMinArgs(args []string, n int) error {
+	if len(args) < n {
+		return usageError("expected at least %d argument(s), got %d", n, len(args))
+	}
+	return nil
+}
+
+// splitList splits a comma separated flag value, dropping empty items.
+func splitList(value string) []string {
+	return splitGroups([]string{value})
+}
+
+// splitGroups accepts groups given as separate arguments, comma separated, or both.
+func splitGroups(args []string) []string {
+	groups := []string{}
+	for _, arg := range args {
+		for _, g := range strings.Split(arg, ",") {
+			g = strings.TrimSpace(g)
+			if g != "" {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+func commands() *command {
+	return &command{
+		summary: "Configures the Kong reverse proxy in front of the EdgeX microservices and manages its accounts.",
+		subcommands: []*command{
+			initCommand(),
+			resetCommand(),
+			userCommand(),
+			groupCommand(),
+			tokenCommand(),
+			certCommand(),
+			statusCommand(),
+			configCommand(),
+		},
+	}
+}
+
+func initCommand() *command {
+	return &command{
+		name:    "init",
+		summary: "Set up the certificate, services, routes and plugins of the reverse proxy",
+		setup: func(fs *flag.FlagSet) runFunc {
+			reconcile := fs.Bool("reconcile", false, "update drifted and prune stale Kong objects so they match the configuration")
+			return func(env *environment, args []string) error {
+				err := expectArgs(args, 0)
+				if err == nil {
+					err = env.checkProxy()
+				}
+				if err != nil {
+					return err
+				}
+				if *reconcile {
+					return withCode(exitProxy, env.service.Reconcile())
+				}
+				return withCode(exitProxy, env.service.Init())
+			}
+		},
+	}
+}
+
+func resetCommand() *command {
+	return &command{
+		name:    "reset",
+		summary: "Remove the services, routes, consumers, plugins and certificates created by edgexproxy",
+		setup: func(fs *flag.FlagSet) runFunc {
+			all := fs.Bool("all", false, "also remove objects not tagged as created by edgexproxy")
+			return func(env *environment, args []string) error {
+				err := expectArgs(args, 0)
+				if err == nil {
+					err = env.checkProxy()
+				}
+				if err != nil {
+					return err
+				}
+				return withCode(exitProxy, env.service.ResetProxy(*all))
+			}
+		},
+	}
+}
+
+func userCommand() *command {
+	return &command{
+		name:    "user",
+		summary: "Manage the accounts allowed to access the EdgeX services",
+		subcommands: []*command{
+			{
+				name:    "add",
+				args:    "<username>",
+				summary: "Create an account and issue its access token",
+				setup: func(fs *flag.FlagSet) runFunc {
+					group := fs.String("group", "user", "comma separated `groups` the account belongs to")
+					scope := fs.String("scope", "", "comma separated oauth2 `scopes` the token is requested with, the default scope when empty")
+					method := fs.String("method", "", "authentication `method` the token is issued for, the default one of [kongauth] when empty")
+					tokenFile := fs.String("tokenfile", "accessToken.json", "`file` the access token is saved to")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						c := env.consumer(args[0])
+						err = c.Create(worker.EdgeXService)
+						if err == nil {
+							err = c.AssociateWithGroups(splitList(*group))
+						}
+						if err != nil {
+							return withCode(exitUser, err)
+						}
+						return issueToken(env, c, *method, splitList(*scope), *tokenFile)
+					}
+				},
+			},
+			{
+				name:    "del",
+				args:    "<username>",
+				summary: "Delete an account together with its credentials",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return withCode(exitUser, env.consumer(args[0]).Delete())
+					}
+				},
+			},
+			{
+				name:    "list",
+				summary: "List the accounts created by edgexproxy with their groups and credentials",
+				setup: func(fs *flag.FlagSet) runFunc {
+					all := fs.Bool("all", false, "also list accounts not tagged as created by edgexproxy")
+					format := fs.String("format", formatTable, "output `format`: table, json or yaml")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 0)
+						if err == nil {
+							err = checkFormat(*format)
+						}
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						tag := env.consumerCfg.GetProxyManagedTag()
+						if *all {
+							tag = ""
+						}
+						consumers, err := worker.InspectConsumers(env.connect, tag)
+						if err != nil {
+							return withCode(exitUser, err)
+						}
+						return writeConsumers(env.out, *format, consumers)
+					}
+				},
+			},
+			{
+				name:    "show",
+				args:    "<username>",
+				summary: "Show an account with its groups and credentials",
+				setup: func(fs *flag.FlagSet) runFunc {
+					format := fs.String("format", formatTable, "output `format`: table, json or yaml")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = checkFormat(*format)
+						}
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						consumer, err := env.consumer(args[0]).Inspect()
+						if err != nil {
+							return withCode(exitUser, err)
+						}
+						return writeConsumer(env.out, *format, consumer)
+					}
+				},
+			},
+		},
+	}
+}
+
+func groupCommand() *command {
+	return &command{
+		name:    "group",
+		summary: "Manage the ACL groups of an account",
+		subcommands: []*command{
+			{
+				name:    "add",
+				args:    "<username> <group>...",
+				summary: "Add an account to one or more groups",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectMinArgs(args, 2)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return withCode(exitUser, env.consumer(args[0]).AssociateWithGroups(splitGroups(args[1:])))
+					}
+				},
+			},
+			{
+				name:    "del",
+				args:    "<username> <group>...",
+				summary: "Remove an account from one or more groups, keeping its credentials",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectMinArgs(args, 2)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return withCode(exitUser, env.consumer(args[0]).RemoveFromGroups(splitGroups(args[1:])))
+					}
+				},
+			},
+			{
+				name:    "set",
+				args:    "<username> <group>...",
+				summary: "Replace the groups of an account, adding and removing groups as needed",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectMinArgs(args, 2)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return withCode(exitUser, env.consumer(args[0]).SetGroups(splitGroups(args[1:])))
+					}
+				},
+			},
+		},
+	}
+}
+
+func tokenCommand() *command {
+	return &command{
+		name:    "token",
+		summary: "Manage the access tokens of an account",
+		subcommands: []*command{
+			{
+				name:    "create",
+				args:    "<username>",
+				summary: "Issue a new access token for an existing account",
+				setup: func(fs *flag.FlagSet) runFunc {
+					scope := fs.String("scope", "", "comma separated oauth2 `scopes` the token is requested with, the default scope when empty")
+					method := fs.String("method", "", "authentication `method` the token is issued for, the default one of [kongauth] when empty")
+					tokenFile := fs.String("tokenfile", "accessToken.json", "`file` the access token is saved to")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return issueToken(env, env.consumer(args[0]), *method, splitList(*scope), *tokenFile)
+					}
+				},
+			},
+			{
+				name:    "refresh",
+				args:    "<username>",
+				summary: "Exchange the refresh token of an account for a new access token",
+				setup: func(fs *flag.FlagSet) runFunc {
+					tokenFile := fs.String("tokenfile", "accessToken.json", "`file` the refresh token is read from and the new token saved to")
+					refreshToken := fs.String("refreshtoken", "", "refresh `token` to use instead of the one saved in the token file")
+					scope := fs.String("scope", "", "comma separated oauth2 `scopes` the refresh token was issued for, instead of the ones saved in the token file")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						c := env.consumer(args[0])
+						refresh := *refreshToken
+						scopes := splitList(*scope)
+						if refresh == "" || len(scopes) == 0 {
+							saved, err := (&worker.TokenFileWriter{Filename: *tokenFile}).LoadToken(c.Name)
+							if err == nil && saved.RefreshToken == "" {
+								err = fmt.Errorf("%s holds no refresh token", *tokenFile)
+							}
+							if err != nil {
+								return withCode(exitToken, err)
+							}
+							if refresh == "" {
+								refresh = saved.RefreshToken
+							}
+							if len(scopes) == 0 {
+								scopes = strings.Fields(saved.Scope)
+							}
+						}
+						t, err := c.RefreshToken(refresh, scopes)
+						if err != nil {
+							e := fmt.Errorf("failed to refresh access token due to error %s", err.Error())
+							return withCode(exitToken, e)
+						}
+						if env.plan {
+							return nil
+						}
+						return saveToken(env, c, t, *tokenFile)
+					}
+				},
+			},
+			{
+				name:    "list",
+				args:    "<username>",
+				summary: "List the active oauth2 tokens of an account",
+				setup: func(fs *flag.FlagSet) runFunc {
+					format := fs.String("format", formatTable, "output `format`: table, json or yaml")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = checkFormat(*format)
+						}
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						tokens, err := env.consumer(args[0]).Tokens()
+						if err != nil {
+							return withCode(exitToken, err)
+						}
+						return writeTokens(env.out, *format, tokens)
+					}
+				},
+			},
+			{
+				name:    "revoke",
+				args:    "<username> [<token-id>]",
+				summary: "Revoke one oauth2 token of an account, or every token with --all",
+				setup: func(fs *flag.FlagSet) runFunc {
+					all := fs.Bool("all", false, "revoke every token; for methods other than oauth2 the credentials of the account are deleted")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if !*all {
+							err = expectArgs(args, 2)
+						}
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						c := env.consumer(args[0])
+						if !*all {
+							return withCode(exitToken, c.RevokeToken(args[1]))
+						}
+						n, err := c.RevokeTokens()
+						if err != nil {
+							return withCode(exitToken, err)
+						}
+						if !env.plan {
+							fmt.Fprintf(env.out, "revoked %d token(s) or credential(s) of user %s\n", n, c.Name)
+						}
+						return nil
+					}
+				},
+			},
+			{
+				name:    "secret",
+				args:    "<username>",
+				summary: "Show the oauth2 client secret of an account stored in the secret service, or rotate it",
+				setup: func(fs *flag.FlagSet) runFunc {
+					rotate := fs.Bool("rotate", false, "replace the client secret with a new random one")
+					format := fs.String("format", formatTable, "output `format`: table, json or yaml")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 1)
+						if err == nil {
+							err = checkFormat(*format)
+						}
+						if err == nil && *rotate {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						c := env.consumer(args[0])
+						var client *worker.OAuth2Client
+						if *rotate {
+							client, err = c.RotateOAuth2Secret()
+						} else {
+							client, err = c.OAuth2Client()
+						}
+						if err != nil {
+							return withCode(exitToken, err)
+						}
+						if env.plan {
+							return nil
+						}
+						return writeOAuth2Client(env.out, *format, client)
+					}
+				},
+			},
+		},
+	}
+}
+
+func certCommand() *command {
+	return &command{
+		name:    "cert",
+		summary: "Manage the TLS certificate served by the reverse proxy",
+		subcommands: []*command{
+			{
+				name:    "upload",
+				summary: "Upload the certificate pair stored in the secret service to the reverse proxy",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 0)
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+						return withCode(exitProxy, env.service.LoadCert())
+					}
+				},
+			},
+			{
+				name:    "rotate",
+				summary: "Replace the certificate served by the reverse proxy when the one in the secret service has changed",
+				setup: func(fs *flag.FlagSet) runFunc {
+					watch := fs.Bool("watch", false, "keep checking the secret service and rotate the certificate whenever it changes")
+					interval := fs.Duration("interval", 5*time.Minute, "`interval` between two checks with --watch")
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 0)
+						if err == nil && *watch && env.plan {
+							err = usageError("--watch can't be combined with --plan")
+						}
+						if err == nil && *interval <= 0 {
+							err = usageError("--interval must be positive")
+						}
+						if err == nil {
+							err = env.checkProxy()
+						}
+						if err != nil {
+							return err
+						}
+
+						if *watch {
+							stop := make(chan struct{})
+							signals := make(chan os.Signal, 1)
+							signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+							go func() {
+								<-signals
+								close(stop)
+							}()
+							env.service.WatchCert(*interval, stop)
+							return nil
+						}
+
+						fingerprint, changed, err := env.service.RotateCert()
+						if err == nil && changed && !env.plan {
+							err = env.service.ConfirmCert(fingerprint)
+						}
+						if err != nil {
+							return withCode(exitProxy, err)
+						}
+						if changed {
+							fmt.Fprintf(env.out, "certificate rotated, now serving %s\n", fingerprint)
+						} else {
+							fmt.Fprintf(env.out, "certificate %s is up to date\n", fingerprint)
+						}
+						return nil
+					}
+				},
+			},
+		},
+	}
+}
+
+func statusCommand() *command {
+	return &command{
+		name:    "status",
+		summary: "Check that the reverse proxy and the secret service are reachable",
+		setup: func(fs *flag.FlagSet) runFunc {
+			return func(env *environment, args []string) error {
+				err := expectArgs(args, 0)
+				if err != nil {
+					return err
+				}
+
+				proxyErr := env.service.CheckProxyServiceStatus()
+				secretErr := env.service.CheckSecretServiceStatus()
+				fmt.Fprintf(env.out, "reverse proxy: %s\nsecret service: %s\n", statusText(proxyErr), statusText(secretErr))
+				if proxyErr != nil {
+					return withCode(exitUnavailable, proxyErr)
+				}
+				return withCode(exitUnavailable, secretErr)
+			}
+		},
+	}
+}
+
+func configCommand() *command {
+	return &command{
+		name:    "config",
+		summary: "Inspect the configuration file",
+		subcommands: []*command{
+			{
+				name:    "validate",
+				summary: "Check the configuration file for missing or unsupported settings",
+				setup: func(fs *flag.FlagSet) runFunc {
+					return func(env *environment, args []string) error {
+						err := expectArgs(args, 0)
+						if err != nil {
+							return err
+						}
+						err = env.validate()
+						if err != nil {
+							return withCode(exitConfig, err)
+						}
+						fmt.Fprintf(env.out, "%s is valid\n", env.configFile)
+						return nil
+					}
+				},
+			},
+		},
+	}
+}
+
+// issueToken creates an access token of method for the account, prints it and saves
+// it to filename. The default authentication method is used when method is empty.
+func issueToken(env *environment, c *worker.Consumer, method string, scopes []string, filename string) error {
+	if method == "" {
+		method = c.Cfg.GetProxyAuthMethod()
+	}
+	t, err := c.CreateMethodToken(method, scopes)
+	if err != nil {
+		e := fmt.Errorf("failed to create access token for edgex service due to error %s", err.Error())
+		return withCode(exitToken, e)
+	}
+	if env.plan {
+		return nil
+	}
+
+	return saveToken(env, c, t, filename)
+}
+
+// tokenUsage tells how credentials other than bearer tokens are sent to the reverse proxy.
+var tokenUsage = map[string]string{
+	"apikey": "send it in the apikey header of every request",
+	"basic":  "send it as the credentials of an Authorization: Basic header",
+	"hmac":   "sign requests with it as the hmac-auth secret, using the user name as username",
+}
+
+// saveToken prints the access token of the account and saves it to filename.
+// Client certificates are only saved, along with their private key.
+func saveToken(env *environment, c *worker.Consumer, t *worker.KongOauth2Token, filename string) error {
+	tf := &worker.TokenFileWriter{Filename: filename}
+	if t.ClientCert != "" {
+		fmt.Fprintf(env.out, "the client certificate for user %s and its key are saved in %s, the certificate expires in %d seconds\n", c.Name, filename, t.Expires)
+		return withCode(exitToken, tf.SaveToken(c.Name, t))
+	}
+	fmt.Fprintf(env.out, "the access token for user %s is: %s. Please keep the token for accessing edgex services\n", c.Name, t.AccessToken)
+	if usage, ok := tokenUsage[t.TokenType]; ok {
+		fmt.Fprintln(env.out, usage)
+	}
+	if t.RefreshToken != "" {
+		fmt.Fprintf(env.out, "the token expires in %d seconds, run token refresh with the refresh token saved in %s to renew it\n", t.Expires, filename)
+	}
+	return withCode(exitToken, tf.SaveToken(c.Name, t))
+}
+
+func statusText(err error) string {
+	if err != nil {
+		return "down"
+	}
+	return "up"
+}
@@ -17,11 +17,10 @@
 package main
 
 import (
-	"crypto/tls"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	logger "github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
@@ -36,100 +35,89 @@ func CreateLogging() logger.LoggingClient {
 }
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	if len(os.Args) < 2 {
-		worker.HelpCallback()
+func run(args []string) int {
+	prog := filepath.Base(os.Args[0])
+	root := commands()
+	env := &environment{out: os.Stdout}
+
+	globals := newFlagSet(prog)
+	globals.BoolVar(&env.useConsul, "consul", false, "retrieve configuration from consul server")
+	globals.BoolVar(&env.insecureSkipVerify, "insecureskipverify", false, "skip TLS verification of the secret service and the reverse proxy, only for development")
+	globals.BoolVar(&env.insecureSkipVerify, "insureskipverify", false, "same as --insecureskipverify, kept for existing scripts")
+	globals.BoolVar(&env.plan, "plan", false, "print the Kong and Vault calls the command would make instead of making them")
+	globals.StringVar(&env.configFile, "configfile", "res/configuration.toml", "configuration `file`")
+
+	err := globals.Parse(args)
+	if err == flag.ErrHelp {
+		printUsage(os.Stdout, prog, nil, root, nil, globals)
+		return exitOK
 	}
-	useConsul := flag.Bool("consul", false, "retrieve configuration from consul server")
-	insecureSkipVerify := flag.Bool("insureskipverify", true, "skip server side SSL verification, mainly for self-signed cert")
-	initNeeded := flag.Bool("init", false, "run init procedure for security service.")
-	resetNeeded := flag.Bool("reset", false, "reset reverse proxy by removing all services/routes/consumers")
-	userTobeCreated := flag.String("useradd", "", "user that needs to be added to consume the edgex services")
-	userofGroup := flag.String("group", "user", "group that the user belongs to. By default it is in user group")
-	userTobeDeleted := flag.String("userdel", "", "user that needs to be deleted from the edgex services")
-	configFileLocation := flag.String("configfile", "res/configuration.toml", "configuration file")
-
-	flag.Usage = worker.HelpCallback
-	flag.Parse()
-
-	config, err := worker.LoadTomlConfig(*configFileLocation)
 	if err != nil {
-		lc.Error("failed to retrieve config data from local file. Please make sure res/configuration.toml file exists with correct formats")
-		return
+		fmt.Fprintln(os.Stderr, err.Error())
+		printUsage(os.Stderr, prog, nil, root, nil, globals)
+		return exitUsage
 	}
 
-	if *useConsul {
-		lc.Info("retrieving config data from Consul")
+	rest := globals.Args()
+	if len(rest) > 0 && rest[0] == "help" {
+		path, cmd, _ := resolve(root, rest[1:])
+		fs := newFlagSet(prog)
+		if cmd.setup != nil {
+			cmd.setup(fs)
+		}
+		printUsage(os.Stdout, prog, path, cmd, fs, globals)
+		return exitOK
 	}
 
-	client := getNewClient(*insecureSkipVerify)
-	er := worker.EdgeXRequestor{ProxyBaseURL: config.GetProxyBaseURL(), SecretSvcBaseURL: config.GetSecretSvcBaseURL(), Client: client}
-	s := &worker.Service{Connect: &er, CertCfg: config, ServiceCfg: config}
-
-	err = s.CheckProxyServiceStatus()
-	if err != nil {
-		lc.Error(err.Error())
-		return
+	path, cmd, rest := resolve(root, rest)
+	fs := newFlagSet(prog)
+	var runner runFunc
+	if cmd.setup != nil {
+		runner = cmd.setup(fs)
 	}
-
-	if *initNeeded == true && *resetNeeded == true {
-		lc.Error("can't run initialization and reset at the same time for security service")
-		return
+	positional, err := parseArgs(fs, rest)
+	if err == flag.ErrHelp {
+		printUsage(os.Stdout, prog, path, cmd, fs, globals)
+		return exitOK
 	}
-
-	if *initNeeded == true {
-		err = s.Init()
-		if err != nil {
-			lc.Error(err.Error())
+	if err == nil && runner == nil {
+		if len(positional) > 0 {
+			err = fmt.Errorf("unknown command %q", positional[0])
+		} else {
+			err = fmt.Errorf("a command is required")
 		}
 	}
-
-	if *resetNeeded == true {
-		err = s.ResetProxy()
-		if err != nil {
-			lc.Error(err.Error())
-		}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		printUsage(os.Stderr, prog, path, cmd, fs, globals)
+		return exitUsage
 	}
 
-	if *userTobeCreated != "" && *userofGroup != "" {
-		c := &worker.Consumer{Name: *userTobeCreated, Connect: &er, Cfg: config}
-
-		err := c.Create(worker.EdgeXService)
-		if err != nil {
-			lc.Error(err.Error())
-			return
-		}
-
-		err = c.AssociateWithGroup(*userofGroup)
-		if err != nil {
-			lc.Error(err.Error())
-			return
-		}
-
-		t, err := c.CreateToken()
-		if err != nil {
-			lc.Error(fmt.Sprintf("failed to create access token for edgex service due to error %s", err.Error()))
-			return
-		}
-
-		fmt.Println(fmt.Sprintf("the access token for user %s is: %s. Please keep the token for accessing edgex services", *userTobeCreated, t))
-
-		tf := &worker.TokenFileWriter{Filename: "accessToken.json"}
-		err = tf.Save(*userTobeCreated, t)
-		if err != nil {
-			lc.Error(err.Error())
-		}
+	err = env.load()
+	if err == nil {
+		err = runner(env, positional)
 	}
-
-	if *userTobeDeleted != "" {
-		t := &worker.Consumer{Name: *userTobeCreated, Connect: &er, Cfg: config}
-		t.Delete()
+	if env.recorder != nil {
+		printPlan(env.recorder)
 	}
+	if err != nil {
+		lc.Error(err.Error())
+		if exitCode(err) == exitUsage {
+			printUsage(os.Stderr, prog, path, cmd, fs, globals)
+		}
+	}
+	return exitCode(err)
 }
 
-func getNewClient(skipVerify bool) *http.Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+func printPlan(pr *worker.PlanRecorder) {
+	err := pr.Print(os.Stdout)
+	if err == nil {
+		err = pr.PrintJSON(os.Stdout)
+	}
+	if err != nil {
+		lc.Error(fmt.Sprintf("failed to print plan with error %s", err.Error()))
 	}
-	return &http.Client{Timeout: 10 * time.Second, Transport: tr}
 }
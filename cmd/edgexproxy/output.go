@@ -0,0 +1,139 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	worker "github.com/edgexfoundry/security-api-gateway/internal/pkg/edgexproxy"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func checkFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return nil
+	}
+	return usageError("unsupported output format %q, expected %s, %s or %s", format, formatTable, formatJSON, formatYAML)
+}
+
+// writeData writes v as JSON or YAML. Tables are rendered by the caller.
+func writeData(w io.Writer, format string, v interface{}) error {
+	var data []byte
+	var err error
+	if format == formatYAML {
+		data, err = yaml.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", " ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func writeConsumers(w io.Writer, format string, consumers []worker.ConsumerInfo) error {
+	if format != formatTable {
+		return writeData(w, format, consumers)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tID\tGROUPS\tJWT KEYS\tOAUTH2 CLIENTS\tCREATED")
+	for _, c := range consumers {
+		keys := []string{}
+		for _, j := range c.JWT {
+			keys = append(keys, j.Key)
+		}
+		clients := []string{}
+		for _, o := range c.OAuth2 {
+			clients = append(clients, o.ClientID)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Username, c.ID, joinOrDash(c.Groups), joinOrDash(keys), joinOrDash(clients), c.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func writeConsumer(w io.Writer, format string, c *worker.ConsumerInfo) error {
+	if format != formatTable {
+		return writeData(w, format, c)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Username:\t%s\n", c.Username)
+	fmt.Fprintf(tw, "ID:\t%s\n", c.ID)
+	fmt.Fprintf(tw, "Created:\t%s\n", c.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Groups:\t%s\n", joinOrDash(c.Groups))
+	for _, j := range c.JWT {
+		fmt.Fprintf(tw, "JWT credential:\tkey=%s algorithm=%s created=%s\n", j.Key, j.Algorithm, j.CreatedAt.Format(time.RFC3339))
+	}
+	for _, o := range c.OAuth2 {
+		fmt.Fprintf(tw, "OAuth2 application:\tname=%s client_id=%s created=%s\n", o.Name, o.ClientID, o.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func writeTokens(w io.Writer, format string, tokens []worker.TokenInfo) error {
+	if format != formatTable {
+		return writeData(w, format, tokens)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSCOPE\tCREATED\tEXPIRES")
+	for _, t := range tokens {
+		scope := "-"
+		if t.Scope != "" {
+			scope = t.Scope
+		}
+		expires := "never"
+		if t.ExpiresAt != nil {
+			expires = t.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.ID, scope, t.CreatedAt.Format(time.RFC3339), expires)
+	}
+	return tw.Flush()
+}
+
+func writeOAuth2Client(w io.Writer, format string, client *worker.OAuth2Client) error {
+	if format != formatTable {
+		return writeData(w, format, client)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Client ID:\t%s\n", client.ClientID)
+	fmt.Fprintf(tw, "Client secret:\t%s\n", client.ClientSecret)
+	return tw.Flush()
+}
+
+func joinOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ",")
+}
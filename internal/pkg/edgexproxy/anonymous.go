@@ -0,0 +1,169 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// initAnonymous creates the consumer Kong falls back to when none of the
+// authentication methods accepts a request, and returns its id for the auth
+// plugins. The consumer belongs to a group of its own name, which acls keep out
+// of every route but the public ones. It returns an empty id when no anonymous
+// consumer is configured.
+func (s *Service) initAnonymous() (string, error) {
+	name := s.ServiceCfg.GetProxyAnonymous()
+	if name == "" {
+		return "", nil
+	}
+
+	path := ConsumersPath + name
+	body := &KongConsumer{Tags: s.managedTags()}
+	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Put(path).BodyForm(body).Request()
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to set up anonymous consumer %s with error %s", name, err.Error())
+		return "", errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		b, _ := ioutil.ReadAll(resp.Body)
+		e := fmt.Sprintf("failed to set up anonymous consumer %s with error %s,%s", name, resp.Status, string(b))
+		lc.Error(e)
+		return "", errors.New(e)
+	}
+	consumer := KongConsumerResponse{}
+	json.NewDecoder(resp.Body).Decode(&consumer)
+	if consumer.ID == "" {
+		// a dry run answers without the consumer, its name stands in for the id
+		consumer.ID = name
+	}
+	lc.Info(fmt.Sprintf("successful to set up anonymous consumer %s", name))
+
+	acl := &acctParams{name}
+	req, err = sling.New().Base(s.Connect.GetProxyBaseURL()).Post(path + "/acls").BodyForm(acl).Request()
+	if err != nil {
+		return "", err
+	}
+	resp, err = s.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to add anonymous consumer %s to its group with error %s", name, err.Error())
+		return "", errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
+		return consumer.ID, nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to add anonymous consumer %s to its group with error %s,%s", name, resp.Status, string(b))
+	lc.Error(e)
+	return "", errors.New(e)
+}
+
+// anonymousACLParams returns the acl keeping the anonymous consumer out of the
+// services no other acl restricts, or nil when there is no anonymous consumer.
+func (s *Service) anonymousACLParams() *KongACLPlugin {
+	anonymous := s.ServiceCfg.GetProxyAnonymous()
+	if anonymous == "" {
+		return nil
+	}
+	return &KongACLPlugin{
+		Name:      s.ServiceCfg.GetProxyACLName(),
+		BlackList: anonymous,
+		Tags:      s.managedTags(),
+	}
+}
+
+// publicRoutes returns the routes letting anonymous requests reach the public
+// routes of a service.
+func (s *Service) publicRoutes(svc service) []serviceRoute {
+	anonymous := s.ServiceCfg.GetProxyAnonymous()
+	if anonymous == "" {
+		return nil
+	}
+
+	routes := []serviceRoute{}
+	for _, p := range s.ServiceCfg.GetProxyPublicRoutes() {
+		route := s.pathRoute(svc, "public", p)
+		route.ACL = s.publicACLParams(svc, anonymous)
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// publicACLParams lets the anonymous consumer through to a public route along
+// with the groups allowed on the rest of the service. A service open to every
+// group but the anonymous consumer only lets anonymous requests through.
+func (s *Service) publicACLParams(svc service, anonymous string) *KongACLPlugin {
+	if len(svc.ACLDeny) > 0 && s.ServiceCfg.GetProxyACLWhiteList() == "" {
+		return &KongACLPlugin{
+			Name:      ACLPlugin,
+			BlackList: strings.Join(svc.ACLDeny, ","),
+			Tags:      s.managedTags(),
+		}
+	}
+
+	set := map[string]bool{anonymous: true}
+	for _, g := range svc.ACLAllow {
+		set[g] = true
+	}
+	if len(svc.ACLAllow) == 0 {
+		for _, r := range svc.Roles {
+			for _, g := range r.Groups {
+				set[g] = true
+			}
+		}
+	}
+	if len(svc.ACLAllow) == 0 && len(svc.Roles) == 0 {
+		for _, g := range splitGroupList(s.ServiceCfg.GetProxyACLWhiteList()) {
+			set[g] = true
+		}
+	}
+	for _, g := range svc.ACLDeny {
+		if g != anonymous {
+			delete(set, g)
+		}
+	}
+	groups := []string{}
+	for g := range set {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	return s.aclPluginParams(ACLPlugin, strings.Join(groups, ","))
+}
+
+func splitGroupList(list string) []string {
+	groups := []string{}
+	for _, g := range strings.Split(list, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
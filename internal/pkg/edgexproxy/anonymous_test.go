@@ -0,0 +1,88 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testAnonymousServiceConfig struct {
+	testServiceConfig
+}
+
+func (ts *testAnonymousServiceConfig) GetProxyAnonymous() string {
+	return "anonymous"
+}
+
+func (ts *testAnonymousServiceConfig) GetProxyPublicRoutes() []string {
+	return []string{"/api/v1/ping"}
+}
+
+func TestInitAnonymous(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "PUT /consumers/anonymous":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"a1","username":"anonymous"}`))
+		case "POST /consumers/anonymous/acls":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := &Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testAnonymousServiceConfig{}}
+	id, err := svc.initAnonymous()
+	if err != nil {
+		t.Fatalf("failed to set up anonymous consumer: %s", err.Error())
+	}
+	if id != "a1" {
+		t.Errorf("expected the id of the anonymous consumer, got %s", id)
+	}
+
+	svc = &Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+	id, err = svc.initAnonymous()
+	if err != nil || id != "" {
+		t.Errorf("expected no anonymous consumer without configuration, got %s", id)
+	}
+}
+
+func TestPublicRoutes(t *testing.T) {
+	svc := &Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testAnonymousServiceConfig{}}
+	routes := svc.serviceRoutes(service{Name: "coredata", Host: "edgex-core-data", Port: "48080", ACLAllow: []string{"admin"}})
+	if len(routes) != 2 {
+		t.Fatalf("expected the service route and a public route, got %d routes", len(routes))
+	}
+	public := routes[1]
+	if public.Service == nil || public.Service.Name != "coredata-public-api-v1-ping" || public.Service.Path != "/api/v1/ping" {
+		t.Errorf("expected a service of its own for the public route, got %+v", public.Service)
+	}
+	if public.Route.Paths[0] != "/coredata/api/v1/ping$" {
+		t.Errorf("expected the public route to be under the service path, got %v", public.Route.Paths)
+	}
+	if public.ACL == nil || public.ACL.WhiteList != "admin,anonymous" {
+		t.Errorf("expected the public route to allow the service groups and the anonymous consumer, got %+v", public.ACL)
+	}
+
+	acl := svc.serviceACLParams(service{Name: "coredata", ACLDeny: []string{"guest"}})
+	if acl.BlackList != "guest,anonymous" {
+		t.Errorf("expected the anonymous consumer to be denied along with guest, got %s", acl.BlackList)
+	}
+}
@@ -0,0 +1,326 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// authMethod is an authentication method the reverse proxy can enforce.
+type authMethod interface {
+	// plugins lists the Kong plugins enforcing the method for the configured
+	// services. Requests the plugins can't authenticate are passed on as the
+	// consumer with the id anonymous, or rejected when it is empty.
+	plugins(s *Service, anonymous string) ([]kongPlugin, error)
+	// issue creates a credential of the method for the consumer. Scopes only
+	// apply to methods that support them.
+	issue(c *Consumer, scopes []string) (*KongOauth2Token, error)
+	// revoke removes every credential of the method issued to the consumer and
+	// returns how many were removed.
+	revoke(c *Consumer) (int, error)
+}
+
+// authMethods are the supported authentication methods by their kongauth name.
+var authMethods = map[string]authMethod{
+	AuthMethodJWT:       jwtAuth{},
+	AuthMethodOAuth2:    oauth2Auth{},
+	AuthMethodKeyAuth:   keyAuth{},
+	AuthMethodBasicAuth: basicAuth{},
+	AuthMethodHMACAuth:  hmacAuth{},
+	AuthMethodMTLSAuth:  mtlsAuth{},
+}
+
+func lookupAuthMethod(name string) (authMethod, error) {
+	m, ok := authMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported authentication method %s, use one of %s", name, strings.Join(AuthMethodNames(), ", "))
+	}
+	return m, nil
+}
+
+// AuthMethodNames returns the names of the supported authentication methods.
+func AuthMethodNames() []string {
+	names := []string{}
+	for name := range authMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type jwtAuth struct{}
+
+func (jwtAuth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	params := s.jwtPluginParams()
+	params.Anonymous = anonymous
+	return s.servicePlugins(AuthMethodJWT, params), nil
+}
+
+func (jwtAuth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	ignoreScopes(AuthMethodJWT, scopes)
+	t, err := c.createJWTToken()
+	if err != nil {
+		return nil, err
+	}
+	return &KongOauth2Token{TokenType: "bearer", AccessToken: t, Expires: c.Cfg.GetProxyAuthTTL()}, nil
+}
+
+// revoke deletes the jwt credentials the tokens are verified with, since jwt tokens
+// can't be revoked one by one, along with the signing key kept in the secret
+// service. The next token create issues a new credential.
+func (jwtAuth) revoke(c *Consumer) (int, error) {
+	n, err := c.deleteCredentials(AuthMethodJWT)
+	if err != nil {
+		return n, err
+	}
+	if isAsymmetricJWT(c.Cfg.GetProxyJWTAlgorithm()) {
+		err = c.secrets().deleteSecret(c.jwtKeyPath())
+	}
+	return n, err
+}
+
+type oauth2Auth struct{}
+
+// plugins attaches an oauth2 plugin to every service so each accepts the tokens
+// of its own scopes. The public routes of a service accept the same tokens.
+func (oauth2Auth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	plugins := []kongPlugin{}
+	for _, service := range s.ServiceCfg.GetEdgeXSvcs() {
+		params := s.oauth2PluginParams(service)
+		params.Anonymous = anonymous
+		plugins = append(plugins, kongPlugin{Name: AuthMethodOAuth2, Service: service.Name, Params: params})
+		for _, route := range s.publicRoutes(service) {
+			plugins = append(plugins, kongPlugin{Name: AuthMethodOAuth2, Service: route.Service.Name, Params: params})
+		}
+	}
+	return plugins, nil
+}
+
+func (oauth2Auth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	return c.createOAuth2Token(scopes)
+}
+
+func (oauth2Auth) revoke(c *Consumer) (int, error) {
+	tokens, err := c.Tokens()
+	if err != nil {
+		return 0, err
+	}
+	for i, t := range tokens {
+		err = (&Resource{t.ID, c.Connect}).Remove(OAuth2TokensPath)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(tokens), nil
+}
+
+// keyAuth authenticates requests by a static api key sent in the apikey header.
+type keyAuth struct{}
+
+func (keyAuth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	params := &KongKeyAuthPlugin{
+		Name:            AuthMethodKeyAuth,
+		KeyNames:        KeyAuthHeader,
+		HideCredentials: "true",
+		Anonymous:       anonymous,
+		Tags:            s.managedTags(),
+	}
+	return s.servicePlugins(AuthMethodKeyAuth, params), nil
+}
+
+// issue adds a new key generated by the reverse proxy to the consumer. Keys
+// issued before stay valid until they are revoked.
+func (keyAuth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	ignoreScopes(AuthMethodKeyAuth, scopes)
+	path := fmt.Sprintf("%s%s/%s", ConsumersPath, c.Name, AuthMethodKeyAuth)
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Post(path).Request()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to create api key for consumer %s with error %s", c.Name, err.Error())
+		return nil, errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		cred := KongKeyAuthResponse{}
+		err = json.NewDecoder(resp.Body).Decode(&cred)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode api key of consumer %s with error %s", c.Name, err.Error())
+		}
+		lc.Info(fmt.Sprintf("successful to create api key for consumer %s", c.Name))
+		return &KongOauth2Token{TokenType: KeyAuthHeader, AccessToken: cred.Key}, nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to create api key for consumer %s with error %s,%s", c.Name, resp.Status, string(b))
+	lc.Error(e)
+	return nil, errors.New(e)
+}
+
+func (keyAuth) revoke(c *Consumer) (int, error) {
+	return c.deleteCredentials(AuthMethodKeyAuth)
+}
+
+// basicAuth authenticates requests by HTTP basic credentials. The consumer name
+// is the username, and every token create sets a new random password.
+type basicAuth struct{}
+
+func (basicAuth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	params := &KongBasicAuthPlugin{
+		Name:            AuthMethodBasicAuth,
+		HideCredentials: "true",
+		Anonymous:       anonymous,
+		Tags:            s.managedTags(),
+	}
+	return s.servicePlugins(AuthMethodBasicAuth, params), nil
+}
+
+// issue returns the credentials encoded as the value of a Basic authorization header.
+func (basicAuth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	ignoreScopes(AuthMethodBasicAuth, scopes)
+	password, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = c.putNamedCredential(AuthMethodBasicAuth, &KongBasicAuthCredential{Username: c.Name, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	t := base64.StdEncoding.EncodeToString([]byte(c.Name + ":" + password))
+	return &KongOauth2Token{TokenType: "basic", AccessToken: t}, nil
+}
+
+func (basicAuth) revoke(c *Consumer) (int, error) {
+	return c.deleteCredentials(AuthMethodBasicAuth)
+}
+
+// hmacAuth authenticates requests signed with a secret shared with the reverse
+// proxy. The consumer name is the username, and every token create sets a new
+// random secret.
+type hmacAuth struct{}
+
+func (hmacAuth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	params := &KongHMACAuthPlugin{
+		Name:            AuthMethodHMACAuth,
+		HideCredentials: "true",
+		Anonymous:       anonymous,
+		Tags:            s.managedTags(),
+	}
+	return s.servicePlugins(AuthMethodHMACAuth, params), nil
+}
+
+// issue returns the secret requests of the consumer must be signed with.
+func (hmacAuth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	ignoreScopes(AuthMethodHMACAuth, scopes)
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = c.putNamedCredential(AuthMethodHMACAuth, &KongHMACAuthCredential{Username: c.Name, Secret: secret})
+	if err != nil {
+		return nil, err
+	}
+	return &KongOauth2Token{TokenType: "hmac", AccessToken: secret}, nil
+}
+
+func (hmacAuth) revoke(c *Consumer) (int, error) {
+	return c.deleteCredentials(AuthMethodHMACAuth)
+}
+
+// servicePlugins attaches the plugin with params to every service requests are
+// authenticated for. The plugins aren't installed globally, so that the open
+// paths of the services stay reachable without credentials.
+func (s *Service) servicePlugins(name string, params interface{}) []kongPlugin {
+	plugins := []kongPlugin{}
+	for _, service := range s.authServices() {
+		plugins = append(plugins, kongPlugin{Name: name, Service: service, Params: params})
+	}
+	return plugins
+}
+
+func ignoreScopes(method string, scopes []string) {
+	if len(scopes) > 0 {
+		lc.Info(fmt.Sprintf("scopes only apply to oauth2 tokens, ignoring them for %s", method))
+	}
+}
+
+// putNamedCredential creates the credential of the plugin named after the
+// consumer, or updates it when the consumer already has one.
+func (c *Consumer) putNamedCredential(plugin string, body interface{}) error {
+	path := fmt.Sprintf("%s%s/%s", ConsumersPath, c.Name, plugin)
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Post(path).BodyForm(body).Request()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to create %s credential for consumer %s with error %s", plugin, c.Name, err.Error())
+		return errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		req, err = sling.New().Base(c.Connect.GetProxyBaseURL()).Patch(path + "/" + url.PathEscape(c.Name)).BodyForm(body).Request()
+		if err != nil {
+			return err
+		}
+		resp, err = c.Connect.GetHttpClient().Do(req)
+		if err != nil {
+			e := fmt.Sprintf("failed to update %s credential for consumer %s with error %s", plugin, c.Name, err.Error())
+			return errors.New(e)
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		lc.Info(fmt.Sprintf("successful to set up %s credential for consumer %s", plugin, c.Name))
+		return nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to set up %s credential for consumer %s with error %s,%s", plugin, c.Name, resp.Status, string(b))
+	lc.Error(e)
+	return errors.New(e)
+}
+
+// deleteCredentials removes every credential of the plugin from the consumer and
+// returns how many were removed.
+func (c *Consumer) deleteCredentials(plugin string) (int, error) {
+	path := fmt.Sprintf("%s%s/%s/", ConsumersPath, c.Name, plugin)
+	creds := []Item{}
+	err := listKongObjects(c.Connect, path, &creds)
+	if err != nil {
+		return 0, err
+	}
+	for i, cred := range creds {
+		err = (&Resource{cred.ID, c.Connect}).Remove(path)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(creds), nil
+}
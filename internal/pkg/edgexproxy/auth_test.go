@@ -0,0 +1,133 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testEdgeXServiceConfig struct {
+	testServiceConfig
+}
+
+func (ts *testEdgeXServiceConfig) GetEdgeXSvcs() map[string]service {
+	return map[string]service{
+		"coredata": {Name: "coredata", Host: "edgex-core-data", Port: "48080", PublicPaths: []string{"/api/v1/ping"}},
+	}
+}
+
+func TestLookupAuthMethod(t *testing.T) {
+	for _, name := range []string{"jwt", "oauth2", "key-auth", "basic-auth", "hmac-auth"} {
+		_, err := lookupAuthMethod(name)
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+	_, err := lookupAuthMethod("ldap-auth")
+	if err == nil {
+		t.Errorf("expected ldap-auth not to be supported")
+	}
+}
+
+func TestAuthMethodPlugins(t *testing.T) {
+	s := &Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testEdgeXServiceConfig{}}
+	for _, name := range []string{"jwt", "oauth2", "key-auth", "basic-auth", "hmac-auth"} {
+		plugins, err := authMethods[name].plugins(s, "")
+		if err != nil || len(plugins) != 1 || plugins[0].Name != name || plugins[0].path() != "services/coredata/plugins" {
+			t.Errorf("expected a single %s plugin for coredata and none for its open path, got %+v", name, plugins)
+		}
+	}
+}
+
+func TestIssueKeyAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.EscapedPath() != "/consumers/testuser/key-auth" {
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"k1","key":"testkey"}`))
+	}))
+	defer ts.Close()
+
+	co := &Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	token, err := keyAuth{}.issue(co, nil)
+	if err != nil {
+		t.Fatalf("failed to issue api key: %s", err.Error())
+	}
+	if token.TokenType != "apikey" || token.AccessToken != "testkey" {
+		t.Errorf("expected api key testkey, got %+v", token)
+	}
+}
+
+func TestIssueBasicAuthExisting(t *testing.T) {
+	var password string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "POST /consumers/testuser/basic-auth":
+			w.WriteHeader(http.StatusConflict)
+		case "PATCH /consumers/testuser/basic-auth/testuser":
+			b, _ := ioutil.ReadAll(r.Body)
+			for _, field := range strings.Split(string(b), "&") {
+				if strings.HasPrefix(field, "password=") {
+					password = strings.TrimPrefix(field, "password=")
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := &Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	token, err := basicAuth{}.issue(co, nil)
+	if err != nil {
+		t.Fatalf("failed to issue basic credentials: %s", err.Error())
+	}
+	b, _ := base64.StdEncoding.DecodeString(token.AccessToken)
+	if password == "" || string(b) != "testuser:"+password {
+		t.Errorf("expected credentials of testuser with the new password, got %s", string(b))
+	}
+}
+
+func TestRevokeHMACAuth(t *testing.T) {
+	deleted := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data":[{"id":"h1"}]}`))
+	}))
+	defer ts.Close()
+
+	co := &Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	n, err := hmacAuth{}.revoke(co)
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 credential to be revoked, got %d", n)
+	}
+	if len(deleted) != 1 || deleted[0] != "/consumers/testuser/hmac-auth/h1" {
+		t.Errorf("expected only /consumers/testuser/hmac-auth/h1 to be deleted, got %v instead", deleted)
+	}
+}
@@ -0,0 +1,158 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// certExpiryWarning is how long before it expires a certificate is reported as
+// about to expire when it is uploaded.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// checkCertPair makes sure the certificate pair can serve the snis before it
+// replaces the one on the reverse proxy. The key must match the certificate, which
+// must be valid at now, chain to roots and list every SNI among its SANs. The chain
+// is not verified when roots is nil. The certificate may be followed by its
+// intermediate certificates.
+func checkCertPair(cp *CertPair, roots *x509.CertPool, snis []string, now time.Time) (*x509.Certificate, error) {
+	certs, err := parseCertificates(cp.Cert)
+	if err != nil {
+		return nil, err
+	}
+	leaf := certs[0]
+	key, err := parsePrivateKey(cp.Key)
+	if err != nil {
+		return nil, fmt.Errorf("private key %s", err.Error())
+	}
+	if !samePublicKey(leaf.PublicKey, key.Public()) {
+		return nil, errors.New("private key does not match the certificate")
+	}
+
+	if now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate %s expired on %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("certificate %s is not valid before %s", leaf.Subject.CommonName, leaf.NotBefore.Format(time.RFC3339))
+	}
+	if left := leaf.NotAfter.Sub(now); left < certExpiryWarning {
+		lc.Warn(fmt.Sprintf("certificate %s expires in %d days on %s", leaf.Subject.CommonName, int(left.Hours()/24), leaf.NotAfter.Format(time.RFC3339)))
+	}
+
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err = leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s does not verify against the CA with error %s", leaf.Subject.CommonName, err.Error())
+		}
+	}
+
+	for _, sni := range snis {
+		if err = leaf.VerifyHostname(sni); err != nil {
+			return nil, fmt.Errorf("certificate %s does not cover SNI %s, its SANs are %v", leaf.Subject.CommonName, sni, leaf.DNSNames)
+		}
+	}
+	return leaf, nil
+}
+
+// parseCertificates decodes every PEM certificate in certPEM, the leaf first.
+func parseCertificates(certPEM string) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	rest := []byte(certPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate with error %s", err.Error())
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("certificate is not PEM encoded")
+	}
+	return certs, nil
+}
+
+// parsePrivateKey decodes a PEM encoded PKCS #1, EC or PKCS #8 private key.
+func parsePrivateKey(keyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("is not PEM encoded")
+	}
+	var key interface{}
+	var err error
+	if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't be parsed with error %s", err.Error())
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("can't sign")
+	}
+	return signer, nil
+}
+
+func samePublicKey(a crypto.PublicKey, b crypto.PublicKey) bool {
+	da, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	db, err := x509.MarshalPKIXPublicKey(b)
+	return err == nil && bytes.Equal(da, db)
+}
+
+// loadCAPool reads the CA bundle at path, nil when path is empty.
+func loadCAPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	ca, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s with error %s", path, err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no PEM certificate found in CA bundle %s", path)
+	}
+	return pool, nil
+}
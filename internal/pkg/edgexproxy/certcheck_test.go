@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerCert issues a certificate for names that is valid until notAfter,
+// signed by ca.
+func testServerCert(t *testing.T, ca *CertPair, names []string, notAfter time.Time) *CertPair {
+	caCert, caKey, err := parseCA(ca)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err.Error())
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &CertPair{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+func TestCheckCertPair(t *testing.T) {
+	ca := testClientCA(t)
+	caCert, _, _ := parseCA(ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	now := time.Now()
+
+	cp := testServerCert(t, ca, []string{"edgex-kong", "kong"}, now.Add(30*time.Minute))
+	_, err := checkCertPair(cp, roots, []string{"edgex-kong", "kong"}, now)
+	if err != nil {
+		t.Errorf("expected the certificate to be accepted: %s", err.Error())
+	}
+
+	other := testServerCert(t, ca, []string{"edgex-kong"}, now.Add(30*time.Minute))
+	cases := map[string]struct {
+		cp    *CertPair
+		roots *x509.CertPool
+		snis  []string
+		now   time.Time
+	}{
+		"does not match":         {&CertPair{cp.Cert, other.Key}, roots, nil, now},
+		"expired":                {cp, roots, nil, now.Add(time.Hour)},
+		"does not verify":        {cp, x509.NewCertPool(), nil, now},
+		"does not cover SNI":     {cp, roots, []string{"edgex-kong", "edgex-proxy"}, now},
+		"is not PEM encoded":     {&CertPair{"test-cert", cp.Key}, roots, nil, now},
+		"key is not PEM encoded": {&CertPair{cp.Cert, "test-key"}, roots, nil, now},
+	}
+	for want, c := range cases {
+		_, err = checkCertPair(c.cp, c.roots, c.snis, c.now)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error saying the certificate %s, got %v", want, err)
+		}
+	}
+
+	_, err = checkCertPair(cp, nil, []string{"kong"}, now)
+	if err != nil {
+		t.Errorf("expected the chain not to be verified without a CA: %s", err.Error())
+	}
+}
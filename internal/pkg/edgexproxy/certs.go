@@ -20,13 +20,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/dghubble/sling"
 	"io/ioutil"
+	"net/http"
+	"strings"
 )
 
 type CertConfig interface {
 	GetCertPath() string
 	GetTokenPath() string
+	GetClientCAPath() string
+	GetSecretSvcAuth() secretauth
+	GetSecretSvcNamespace() string
+	GetSecretSvcKVVersion() int
+	GetSecretSvcKVMount() string
 }
 
 type Certs struct {
@@ -48,46 +54,185 @@ type auth struct {
 }
 
 func (cs *Certs) getCertPair() (*CertPair, error) {
-	t, err := cs.getSecret(cs.Cfg.GetTokenPath())
+	return cs.getCertPairAt(cs.Cfg.GetCertPath())
+}
+
+// getCertPairAt reads the certificate pair stored in the secret service at path.
+func (cs *Certs) getCertPairAt(path string) (*CertPair, error) {
+	t, err := cs.getToken()
 	if err != nil {
 		return &CertPair{"", ""}, err
 	}
-	cp, err := cs.retrieve(t)
+	cp, err := cs.retrieveAt(t, path)
 	if err != nil {
 		return &CertPair{"", ""}, err
 	}
 	err = cs.validate(cp)
 	if err != nil {
-		return &CertPair{"", ""}, err
+		return &CertPair{"", ""}, fmt.Errorf("%s on path %s", err.Error(), path)
 	}
 	return cp, nil
 }
 
+// getSecret reads the token from the Vault init file at filename, or from a file
+// holding just the token as written by a Vault agent.
 func (cs *Certs) getSecret(filename string) (string, error) {
 	a := auth{}
 	raw, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return a.Token, err
 	}
+	if t := strings.TrimSpace(string(raw)); !strings.HasPrefix(t, "{") {
+		return t, nil
+	}
 
 	err = json.Unmarshal(raw, &a)
 	return a.Token, err
 }
 
 func (cs *Certs) retrieve(t string) (*CertPair, error) {
-	s := sling.New().Set(VaultToken, t)
-	req, err := s.New().Base(cs.Connect.GetSecretSvcBaseURL()).Get(cs.Cfg.GetCertPath()).Request()
+	return cs.retrieveAt(t, cs.Cfg.GetCertPath())
+}
+
+// retrieveAt reads the certificate pair at path. The errors of the secret service
+// are returned as VaultForbiddenError, VaultNotFoundError, VaultSealedError or
+// VaultError, and the request is retried a few times while it is sealed or on standby.
+func (cs *Certs) retrieveAt(t string, path string) (*CertPair, error) {
+	cp := &CertPair{}
+	err := withVaultRetries("read", path, func() error {
+		var err error
+		cp, err = cs.retrieveOnce(t, path)
+		return err
+	})
+	return cp, err
+}
+
+func (cs *Certs) retrieveOnce(t string, path string) (*CertPair, error) {
+	req, err := cs.vaultSling(t).Get(cs.kvPath(path, "data")).Request()
+	if err != nil {
+		return nil, err
+	}
 	resp, err := cs.Connect.GetHttpClient().Do(req)
 	if err != nil {
-		e := fmt.Sprintf("failed to retrieve certificate on path %s with error %s", cs.Cfg.GetCertPath(), err.Error())
+		e := fmt.Sprintf("failed to retrieve certificate on path %s with error %s", path, err.Error())
 		lc.Info(e)
+		return nil, errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = newVaultError("read", path, resp)
+		lc.Error(err.Error())
 		return nil, err
 	}
+	cp := CertPair{}
+	err = decodeSecret(resp.Body, &cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate on path %s with error %s", path, err.Error())
+	}
+	return &cp, nil
+}
+
+// readSecret decodes the data of the Vault secret at path into v and reports
+// whether the secret exists.
+func (cs *Certs) readSecret(path string, v interface{}) (bool, error) {
+	found := false
+	err := withVaultRetries("read", path, func() error {
+		var err error
+		found, err = cs.readSecretOnce(path, v)
+		return err
+	})
+	return found, err
+}
+
+func (cs *Certs) readSecretOnce(path string, v interface{}) (bool, error) {
+	sl, err := cs.vault()
+	if err != nil {
+		return false, err
+	}
+	req, err := sl.Get(cs.kvPath(path, "data")).Request()
+	if err != nil {
+		return false, err
+	}
+	resp, err := cs.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to read secret on path %s with error %s", path, err.Error())
+	}
 	defer resp.Body.Close()
 
-	cc := CertCollect{}
-	json.NewDecoder(resp.Body).Decode(&cc)
-	return &cc.Pair, nil
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, newVaultError("read", path, resp)
+	}
+	err = decodeSecret(resp.Body, v)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode secret on path %s with error %s", path, err.Error())
+	}
+	return true, nil
+}
+
+// writeSecret stores data as the Vault secret at path, replacing any previous value.
+func (cs *Certs) writeSecret(path string, data interface{}) error {
+	return withVaultRetries("write", path, func() error {
+		return cs.writeSecretOnce(path, data)
+	})
+}
+
+func (cs *Certs) writeSecretOnce(path string, data interface{}) error {
+	sl, err := cs.vault()
+	if err != nil {
+		return err
+	}
+	req, err := sl.Post(cs.kvPath(path, "data")).BodyJSON(cs.kvBody(data)).Request()
+	if err != nil {
+		return err
+	}
+	resp, err := cs.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write secret on path %s with error %s", path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		lc.Info(fmt.Sprintf("successful to write secret on path %s", path))
+		return nil
+	}
+	err = newVaultError("write", path, resp)
+	lc.Error(err.Error())
+	return err
+}
+
+// deleteSecret removes the Vault secret at path. Removing a missing secret is not an error.
+func (cs *Certs) deleteSecret(path string) error {
+	return withVaultRetries("delete", path, func() error {
+		return cs.deleteSecretOnce(path)
+	})
+}
+
+func (cs *Certs) deleteSecretOnce(path string) error {
+	sl, err := cs.vault()
+	if err != nil {
+		return err
+	}
+	req, err := sl.Delete(cs.kvPath(path, "metadata")).Request()
+	if err != nil {
+		return err
+	}
+	resp, err := cs.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret on path %s with error %s", path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		lc.Info(fmt.Sprintf("successful to delete secret on path %s", path))
+		return nil
+	}
+	err = newVaultError("delete", path, resp)
+	lc.Error(err.Error())
+	return err
 }
 
 func (cs *Certs) validate(cp *CertPair) error {
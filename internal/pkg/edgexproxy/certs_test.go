@@ -20,7 +20,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 type testRequestor struct {
@@ -51,6 +54,26 @@ func (tc *testCertCfg) GetTokenPath() string {
 	return "test"
 }
 
+func (tc *testCertCfg) GetClientCAPath() string {
+	return ""
+}
+
+func (tc *testCertCfg) GetSecretSvcAuth() secretauth {
+	return secretauth{}
+}
+
+func (tc *testCertCfg) GetSecretSvcNamespace() string {
+	return ""
+}
+
+func (tc *testCertCfg) GetSecretSvcKVVersion() int {
+	return 1
+}
+
+func (tc *testCertCfg) GetSecretSvcKVMount() string {
+	return DefaultKVMount
+}
+
 func TestGetSecret(t *testing.T) {
 	path := "../../../test/test-resp-init.json"
 	cs := Certs{&testRequestor{}, &testCertCfg{}}
@@ -90,6 +113,7 @@ func TestRetrieve(t *testing.T) {
 		if r.Header.Get(VaultToken) != token {
 			t.Errorf("expected request header for %s is %s, got %s instead", VaultToken, token, r.Header.Get(VaultToken))
 		}
+		w.Write([]byte(`{"data":{"cert":"test-cert","key":"test-key"}}`))
 	}))
 	defer ts.Close()
 
@@ -100,3 +124,96 @@ func TestRetrieve(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 }
+
+func TestRetrieveErrors(t *testing.T) {
+	defer func(delay time.Duration) { vaultRetryDelay = delay }(vaultRetryDelay)
+	vaultRetryDelay = 0
+
+	status := http.StatusForbidden
+	sealed := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusServiceUnavailable && sealed > 0 {
+			sealed--
+			w.WriteHeader(status)
+			w.Write([]byte(`{"errors":["Vault is sealed"]}`))
+			return
+		}
+		if status == http.StatusServiceUnavailable {
+			w.Write([]byte(`{"data":{"cert":"test-cert","key":"test-key"}}`))
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+	}))
+	defer ts.Close()
+
+	cs := Certs{&testRequestor{ts.URL}, &testCertCfg{"v1/secret/edgex/pki/tls/edgex-kong"}}
+	_, err := cs.retrieve("token")
+	if e, ok := err.(*VaultForbiddenError); !ok || e.Status != http.StatusForbidden || e.Errors[0] != "permission denied" {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "v1/secret/edgex/pki/tls/edgex-kong") {
+		t.Errorf("expected the path in the error, got %v", err)
+	}
+
+	status = http.StatusNotFound
+	_, err = cs.retrieve("token")
+	if _, ok := err.(*VaultNotFoundError); !ok {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	sealed = 2
+	cp, err := cs.retrieve("token")
+	if err != nil || cp.Cert != "test-cert" {
+		t.Errorf("expected the certificate once the secret service is unsealed, got %v", err)
+	}
+
+	sealed = vaultRetries
+	_, err = cs.retrieve("token")
+	if _, ok := err.(*VaultSealedError); !ok || sealed != 0 {
+		t.Errorf("expected a sealed error after %d attempts, got %v with %d left", vaultRetries, err, sealed)
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	defer func(delay time.Duration) { vaultRetryDelay = delay }(vaultRetryDelay)
+	vaultRetryDelay = 0
+
+	sealed := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sealed > 0 {
+			sealed--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"errors":["Vault is sealed"]}`))
+			return
+		}
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	os.Setenv("EDGEX_TEST_VAULT_TOKEN", "s.env")
+	defer os.Unsetenv("EDGEX_TEST_VAULT_TOKEN")
+	cs := Certs{&testRequestor{ts.URL}, &testEnvCertCfg{}}
+	sealed = 2
+	err := cs.writeSecret("v1/secret/edgex/test", map[string]string{"key": "value"})
+	if err != nil || sealed != 0 {
+		t.Errorf("expected the write to be retried until the secret service is unsealed, got %v with %d left", err, sealed)
+	}
+
+	sealed = vaultRetries
+	_, err = cs.readSecret("v1/secret/edgex/test", &CertPair{})
+	if _, ok := err.(*VaultSealedError); !ok || sealed != 0 || !strings.Contains(err.Error(), "failed to read secret") {
+		t.Errorf("expected a sealed error reading after %d attempts, got %v with %d left", vaultRetries, err, sealed)
+	}
+
+	err = cs.deleteSecret("v1/secret/edgex/test")
+	if _, ok := err.(*VaultForbiddenError); !ok || !strings.Contains(err.Error(), "permission denied to delete secret") {
+		t.Errorf("expected a forbidden error naming the delete, got %v", err)
+	}
+}
@@ -17,14 +17,42 @@
 package edgexproxy
 
 const (
-	ServicesPath     = "services/"
-	RoutesPath       = "routes/"
-	ConsumersPath    = "consumers/"
-	CertificatesPath = "certificates/"
-	PluginsPath      = "plugins/"
-	SecurityService  = "securityservice"
-	EdgeXService     = "edgex-kong"
-	VaultToken       = "X-Vault-Token"
-	OAuth2GrantType  = "client_credentials"
-	OAuth2Scopes     = "all"
+	ServicesPath            = "services/"
+	RoutesPath              = "routes/"
+	ConsumersPath           = "consumers/"
+	CertificatesPath        = "certificates/"
+	PluginsPath             = "plugins/"
+	OAuth2TokensPath        = "oauth2_tokens/"
+	CACertificatesPath      = "ca_certificates/"
+	SecurityService         = "securityservice"
+	EdgeXService            = "edgex-kong"
+	VaultToken              = "X-Vault-Token"
+	VaultNamespace          = "X-Vault-Namespace"
+	VaultAuthToken          = "token"
+	VaultAuthEnv            = "env"
+	VaultAuthAppRole        = "approle"
+	VaultAuthKubernetes     = "kubernetes"
+	DefaultVaultTokenEnv    = "VAULT_TOKEN"
+	DefaultKubernetesJWT    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultKVMount          = "secret"
+	OAuth2GrantType         = "client_credentials"
+	ACLPlugin               = "acl"
+	RateLimitingPlugin      = "rate-limiting"
+	JWTClaimsToVerify       = "nbf"
+	JWTClaimExpiry          = "exp"
+	JWTAlgorithmHS256       = "HS256"
+	JWTAlgorithmRS256       = "RS256"
+	JWTAlgorithmES256       = "ES256"
+	OAuth2PasswordGrantType = "password"
+	OAuth2RefreshGrantType  = "refresh_token"
+	OAuth2Scopes            = "all"
+	OAuth2DefaultTokenTTL   = 7200
+	DefaultManagedTag       = "edgex-managed"
+	AuthMethodJWT           = "jwt"
+	AuthMethodOAuth2        = "oauth2"
+	AuthMethodKeyAuth       = "key-auth"
+	AuthMethodBasicAuth     = "basic-auth"
+	AuthMethodHMACAuth      = "hmac-auth"
+	AuthMethodMTLSAuth      = "mtls-auth"
+	KeyAuthHeader           = "apikey"
 )
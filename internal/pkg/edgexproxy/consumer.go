@@ -17,7 +17,8 @@
 package edgexproxy
 
 import (
-	"crypto/tls"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,6 +28,8 @@ import (
 	model "github.com/edgexfoundry/go-mod-core-contracts/models"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -43,17 +46,74 @@ type Consumer struct {
 }
 
 type ConsumerConfig interface {
+	CertConfig
 	GetProxyServerName() string
 	GetProxyServerPort() string
 	GetProxyApplicationPortSSL() string
 	GetProxyAuthMethod() string
+	GetProxyAuthMethods() []string
 	GetProxyAuthResource() string
+	GetProxyAuthTTL() int
+	GetProxyRefreshTTL() int
+	GetProxyJWTGroups() bool
+	GetProxyJWTAudience() string
+	GetProxyJWTAlgorithm() string
+	GetProxyJWTKeyPath() string
+	GetProxyOAuth2SecretPath() string
+	GetProxyManagedTag() string
+	GetProxyGroupRateLimits() map[string]ratelimit
+	GetEdgeXSvcs() map[string]service
 }
 
 type acctParams struct {
 	Group string `url:"group"`
 }
 
+// ListConsumers returns the consumers carrying tag, or every consumer when tag is empty.
+func ListConsumers(connect Requestor, tag string) ([]KongConsumerResponse, error) {
+	all := []KongConsumerResponse{}
+	err := listKongObjects(connect, ConsumersPath, &all)
+	if err != nil || tag == "" || !tagsSupported(connect) {
+		return all, err
+	}
+
+	consumers := []KongConsumerResponse{}
+	for _, consumer := range all {
+		if hasTags(consumer.Tags, []string{tag}) {
+			consumers = append(consumers, consumer)
+		}
+	}
+	return consumers, nil
+}
+
+// Show reads the consumer from the reverse proxy.
+func (c *Consumer) Show() (*KongConsumerResponse, error) {
+	consumer := &KongConsumerResponse{}
+	path := fmt.Sprintf("%s%s", ConsumersPath, c.Name)
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Get(path).Request()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to read consumer %s with error %s", c.Name, err.Error())
+		return nil, errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("consumer %s does not exist", c.Name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read consumer %s with errorcode %d", c.Name, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(consumer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode consumer %s with error %s", c.Name, err.Error())
+	}
+	return consumer, nil
+}
+
 func (c *Consumer) Delete() error {
 	r := &Resource{c.Name, c.Connect}
 	return r.Remove(ConsumersPath)
@@ -61,7 +121,8 @@ func (c *Consumer) Delete() error {
 
 func (c *Consumer) Create(service string) error {
 	path := fmt.Sprintf("%s%s", ConsumersPath, c.Name)
-	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Put(path).Request()
+	body := &KongConsumer{Tags: managedTags(c.Connect, c.Cfg.GetProxyManagedTag())}
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Put(path).BodyForm(body).Request()
 	resp, err := c.Connect.GetHttpClient().Do(req)
 	if err != nil {
 		e := fmt.Sprintf("failed to create consumer %s for %s service with error %s", c.Name, service, err.Error())
@@ -98,18 +159,147 @@ func (c *Consumer) AssociateWithGroup(g string) error {
 	return errors.New(e)
 }
 
-func (c *Consumer) CreateToken() (string, error) {
-	if c.Cfg.GetProxyAuthMethod() == "jwt" {
-		lc.Info("autheticate the user with jwt authentication.")
-		return c.createJWTToken()
-	} else if c.Cfg.GetProxyAuthMethod() == "oauth2" {
-		lc.Info("authenticate the user with oauth2 authentication.")
-		return c.createOAuth2Token()
+// AssociateWithGroups adds the consumer to every group in groups and applies
+// the rate limit of its groups.
+func (c *Consumer) AssociateWithGroups(groups []string) error {
+	for _, g := range groups {
+		err := c.AssociateWithGroup(g)
+		if err != nil {
+			return err
+		}
+	}
+	return c.ApplyGroupRateLimit()
+}
+
+// RemoveFromGroups removes the consumer from every group in groups and applies
+// the rate limit of the groups it is left in.
+func (c *Consumer) RemoveFromGroups(groups []string) error {
+	for _, g := range groups {
+		err := c.RemoveFromGroup(g)
+		if err != nil {
+			return err
+		}
+	}
+	return c.ApplyGroupRateLimit()
+}
+
+// RemoveFromGroup removes the consumer from group g. Removing a consumer from a
+// group it does not belong to is not an error.
+func (c *Consumer) RemoveFromGroup(g string) error {
+	path := fmt.Sprintf("%s%s/acls/%s", ConsumersPath, c.Name, url.PathEscape(g))
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Delete(path).Request()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to remove consumer %s from group %s with error %s", c.Name, g, err.Error())
+		return errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		lc.Info(fmt.Sprintf("successful to remove consumer %s from group %s", c.Name, g))
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		lc.Info(fmt.Sprintf("consumer %s is not in group %s", c.Name, g))
+		return nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to remove consumer %s from group %s with error %s,%s", c.Name, g, resp.Status, string(b))
+	lc.Error(e)
+	return errors.New(e)
+}
+
+// Groups returns the groups the consumer belongs to.
+func (c *Consumer) Groups() ([]string, error) {
+	return consumerGroups(c.Connect, c.Name)
+}
+
+func consumerGroups(connect Requestor, name string) ([]string, error) {
+	acls := []KongACLResponse{}
+	err := listKongObjects(connect, fmt.Sprintf("%s%s/acls", ConsumersPath, name), &acls)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(acls))
+	for _, acl := range acls {
+		groups = append(groups, acl.Group)
+	}
+	return groups, nil
+}
+
+// SetGroups makes groups the complete group list of the consumer, adding the
+// missing groups and removing the others, and applies the rate limit of the new
+// groups. The consumer and its credentials are kept.
+func (c *Consumer) SetGroups(groups []string) error {
+	current, err := c.Groups()
+	if err != nil {
+		return err
+	}
+
+	wanted := map[string]bool{}
+	for _, g := range groups {
+		wanted[g] = true
+	}
+	existing := map[string]bool{}
+	for _, g := range current {
+		existing[g] = true
+		if !wanted[g] {
+			err = c.RemoveFromGroup(g)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, g := range groups {
+		if !existing[g] {
+			err = c.AssociateWithGroup(g)
+			if err != nil {
+				return err
+			}
+			existing[g] = true
+		}
+	}
+	return c.ApplyGroupRateLimit()
+}
+
+// CreateToken issues a credential of the default authentication method for the
+// consumer. Only oauth2 tokens come with a refresh token and are limited to
+// scopes, or to the default scope when none are given.
+func (c *Consumer) CreateToken(scopes []string) (*KongOauth2Token, error) {
+	return c.CreateMethodToken(c.Cfg.GetProxyAuthMethod(), scopes)
+}
+
+// CreateMethodToken issues a credential of the given authentication method,
+// which must be one of the configured methods, for the consumer.
+func (c *Consumer) CreateMethodToken(method string, scopes []string) (*KongOauth2Token, error) {
+	if !c.hasAuthMethod(method) {
+		return nil, fmt.Errorf("authentication method %s is not configured", method)
+	}
+	m, err := lookupAuthMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	lc.Info(fmt.Sprintf("authenticate the user with %s authentication.", method))
+	return m.issue(c, scopes)
+}
+
+func (c *Consumer) hasAuthMethod(method string) bool {
+	for _, m := range c.Cfg.GetProxyAuthMethods() {
+		if m == method {
+			return true
+		}
 	}
-	return "", errors.New("unknown authentication method provided")
+	return false
 }
 
 func (c *Consumer) createJWTToken() (string, error) {
+	if alg := c.Cfg.GetProxyJWTAlgorithm(); isAsymmetricJWT(alg) {
+		return c.createSignedJWTToken(alg)
+	}
+
 	jwtCred := JWTCred{}
 	s := sling.New().Set("Content-Type", "application/x-www-form-urlencoded")
 	req, err := s.New().Get(c.Connect.GetProxyBaseURL()).Post(fmt.Sprintf("consumers/%s/jwt", c.Name)).Request()
@@ -124,13 +314,9 @@ func (c *Consumer) createJWTToken() (string, error) {
 		json.NewDecoder(resp.Body).Decode(&jwtCred)
 		lc.Info(fmt.Sprintf("successful on retrieving JWT credential for consumer %s", c.Name))
 
-		// Create the Claims
-		claims := KongJWTClaims{
-			jwtCred.Key,
-			c.Name,
-			jwt.StandardClaims{
-				Issuer: EdgeXService,
-			},
+		claims, err := c.jwtClaims(jwtCred.Key, time.Now())
+		if err != nil {
+			return "", err
 		}
 
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -140,23 +326,72 @@ func (c *Consumer) createJWTToken() (string, error) {
 	return "", errors.New(e)
 }
 
+// jwtClaims builds the claims of a token for the jwt credential key issued at
+// now. The token expires after the configured token_ttl, or never when it is 0.
+func (c *Consumer) jwtClaims(key string, now time.Time) (*KongJWTClaims, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	claims := &KongJWTClaims{
+		ISS:  key,
+		Acct: c.Name,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    EdgeXService,
+			Audience:  c.Cfg.GetProxyJWTAudience(),
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+		},
+	}
+	if ttl := c.Cfg.GetProxyAuthTTL(); ttl > 0 {
+		claims.ExpiresAt = now.Add(time.Duration(ttl) * time.Second).Unix()
+	} else {
+		lc.Info(fmt.Sprintf("token_ttl is not set, the jwt token for consumer %s never expires", c.Name))
+	}
+
+	if c.Cfg.GetProxyJWTGroups() {
+		claims.Groups, err = c.Groups()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// newTokenID returns a random identifier for the jti claim.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token id with error %s", err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
+
 //curl -X POST "http://localhost:8001/consumers/user123/oauth2" -d "name=www.edgexfoundry.org" --data "client_id=user123" -d "client_secret=user123"  -d "redirect_uri=http://www.www.edgexfoundry.org/"
 //curl -k -v https://localhost:8443/{service}/oauth2/token -d "client_id=user123" -d "grant_type=client_credentials" -d "client_secret=user123" -d "scope=email"
-func (c *Consumer) createOAuth2Token() (string, error) {
+func (c *Consumer) createOAuth2Token(scopes []string) (*KongOauth2Token, error) {
+	resource, err := c.oauth2Resource(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) == 0 {
+		scopes = []string{OAuth2Scopes}
+	}
 
 	url := fmt.Sprintf("http://%s:%s/", c.Cfg.GetProxyServerName(), c.Cfg.GetProxyServerPort())
+	client := c.Connect.GetHttpClient()
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	oauth2Client, created, err := c.oauth2ClientForToken()
+	if err != nil {
+		return nil, err
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second, Transport: tr}
-
-	token := KongOauth2Token{}
 	ko := &KongConsumerOauth2{
 		Name:         EdgeXService,
-		ClientID:     c.Name,
-		ClientSecret: c.Name,
+		ClientID:     oauth2Client.ClientID,
+		ClientSecret: oauth2Client.ClientSecret,
 		RedirectURIS: "http://" + EdgeXService,
 	}
 
@@ -164,43 +399,41 @@ func (c *Consumer) createOAuth2Token() (string, error) {
 	resp, err := client.Do(req)
 	if err != nil {
 		lc.Error(fmt.Sprintf("failed to enable oauth2 authentication for consumer %s with error %s", c.Name, err.Error()))
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
 		lc.Info(fmt.Sprintf("successful on enabling oauth2 for consumer %s", c.Name))
 
+		// an application registered before its secret was stored gets the new secret
+		if resp.StatusCode == http.StatusConflict && created {
+			err = c.updateOAuth2Secret(oauth2Client)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// obtain token
 		tokenreq := &KongOuath2TokenRequest{
-			ClientID:     c.Name,
-			ClientSecret: c.Name,
+			ClientID:     oauth2Client.ClientID,
+			ClientSecret: oauth2Client.ClientSecret,
 			GrantType:    OAuth2GrantType,
-			Scope:        OAuth2Scopes,
+			Scope:        strings.Join(scopes, " "),
 		}
-
-		url = fmt.Sprintf("https://%s:%s/", c.Cfg.GetProxyServerName(), c.Cfg.GetProxyApplicationPortSSL())
-		path := fmt.Sprintf("%s/oauth2/token", c.Cfg.GetProxyAuthResource())
-		lc.Info(fmt.Sprintf("creating token on the endpoint of %s", path))
-		req, err := sling.New().Base(url).Post(path).BodyForm(tokenreq).Request()
-		resp, err := client.Do(req)
-		if err != nil {
-			lc.Error(fmt.Sprintf("failed to create oauth2 token for client_id %s with error %s", c.Name, err.Error()))
-			return "", err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
-			defer resp.Body.Close()
-			json.NewDecoder(resp.Body).Decode(&token)
-			lc.Info(fmt.Sprintf("successful on retrieving bearer credential for consumer %s", c.Name))
-			return token.AccessToken, nil
+		// refresh tokens are only issued for the password grant
+		if c.Cfg.GetProxyRefreshTTL() > 0 {
+			provisionKey, err := c.oauth2ProvisionKey(resource)
+			if err != nil {
+				return nil, err
+			}
+			tokenreq.GrantType = OAuth2PasswordGrantType
+			tokenreq.ProvisionKey = provisionKey
+			tokenreq.AuthenticatedUserID = c.Name
 		}
-		b, _ := ioutil.ReadAll(resp.Body)
-		e := fmt.Sprintf("failed to create bearer token for oauth authentication at endpoint oauth2/token with error %s,%s", resp.Status, string(b))
-		return "", errors.New(e)
+		return c.requestOAuth2Token(resource, tokenreq)
 	}
 
 	e := fmt.Sprintf("failed to enable oauth2 for consumer %s with error code %d", c.Name, resp.StatusCode)
-	return "", errors.New(e)
+	return nil, errors.New(e)
 }
@@ -59,10 +59,85 @@ func (te *testConsumerConfig) GetProxyAuthMethod() string {
 	return "jwt"
 }
 
+func (te *testConsumerConfig) GetProxyAuthMethods() []string {
+	return []string{"jwt"}
+}
+
 func (te *testConsumerConfig) GetProxyAuthResource() string {
 	return "all"
 }
 
+func (te *testConsumerConfig) GetProxyAuthTTL() int {
+	return 3600
+}
+
+func (te *testConsumerConfig) GetProxyRefreshTTL() int {
+	return 0
+}
+
+func (te *testConsumerConfig) GetProxyJWTGroups() bool {
+	return false
+}
+
+func (te *testConsumerConfig) GetProxyJWTAudience() string {
+	return ""
+}
+
+func (te *testConsumerConfig) GetProxyJWTAlgorithm() string {
+	return JWTAlgorithmHS256
+}
+
+func (te *testConsumerConfig) GetProxyJWTKeyPath() string {
+	return "v1/secret/edgex/jwt"
+}
+
+func (te *testConsumerConfig) GetProxyOAuth2SecretPath() string {
+	return "v1/secret/edgex/oauth2"
+}
+
+func (te *testConsumerConfig) GetCertPath() string {
+	return ""
+}
+
+func (te *testConsumerConfig) GetTokenPath() string {
+	return "../../../test/test-resp-init.json"
+}
+
+func (te *testConsumerConfig) GetClientCAPath() string {
+	return "v1/secret/edgex/clientca"
+}
+
+func (te *testConsumerConfig) GetSecretSvcAuth() secretauth {
+	return secretauth{}
+}
+
+func (te *testConsumerConfig) GetSecretSvcNamespace() string {
+	return ""
+}
+
+func (te *testConsumerConfig) GetSecretSvcKVVersion() int {
+	return 1
+}
+
+func (te *testConsumerConfig) GetSecretSvcKVMount() string {
+	return DefaultKVMount
+}
+
+func (te *testConsumerConfig) GetEdgeXSvcs() map[string]service {
+	return map[string]service{
+		"coredata": {Name: "coredata", Scopes: []string{"coredata:read", "coredata:write"}},
+		"metadata": {Name: "metadata", Scopes: []string{"metadata:read"}},
+	}
+}
+
+func (te *testConsumerConfig) GetProxyManagedTag() string {
+	return DefaultManagedTag
+}
+
+func (te *testConsumerConfig) GetProxyGroupRateLimits() map[string]ratelimit {
+	return nil
+}
+
 func TestCreate(t *testing.T) {
 	name := "testuser"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -142,9 +217,106 @@ func TestCreateOAuth2Token(t *testing.T) {
 	defer ts.Close()
 
 	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
-	_, err := co.createOAuth2Token()
+	_, err := co.createOAuth2Token(nil)
 	if err != nil {
 		t.Errorf("failed to creat OAuth2 token for consumer")
 		t.Errorf(err.Error())
 	}
 }
+
+func TestRemoveFromGroup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+		if r.Method != "DELETE" {
+			t.Errorf("expected DELETE request, got %s instead", r.Method)
+		}
+
+		if r.URL.EscapedPath() != "/consumers/testuser/acls/groupname" {
+			t.Errorf("expected request to /consumers/testuser/acls/groupname, got %s instead", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	err := co.RemoveFromGroup("groupname")
+	if err != nil {
+		t.Errorf("failed to remove consumer from group")
+		t.Error(err.Error())
+	}
+}
+
+func TestSetGroups(t *testing.T) {
+	added := []string{}
+	removed := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data":[{"group":"admin"},{"group":"user"}]}`))
+		case "POST":
+			added = append(added, r.FormValue("group"))
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			removed = append(removed, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	err := co.SetGroups([]string{"user", "operators"})
+	if err != nil {
+		t.Errorf("failed to set groups of consumer")
+		t.Error(err.Error())
+	}
+	if len(added) != 1 || added[0] != "operators" {
+		t.Errorf("expected only operators to be added, got %v instead", added)
+	}
+	if len(removed) != 1 || removed[0] != "/consumers/testuser/acls/admin" {
+		t.Errorf("expected only admin to be removed, got %v instead", removed)
+	}
+}
+
+type testJWTConsumerConfig struct {
+	testConsumerConfig
+}
+
+func (te *testJWTConsumerConfig) GetProxyJWTGroups() bool {
+	return true
+}
+
+func (te *testJWTConsumerConfig) GetProxyJWTAudience() string {
+	return "edgex"
+}
+
+func TestJWTClaims(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.EscapedPath() != "/consumers/testuser/acls" {
+			t.Errorf("expected request to /consumers/testuser/acls, got %s instead", r.URL.EscapedPath())
+		}
+		w.Write([]byte(`{"data":[{"group":"admin"}]}`))
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testJWTConsumerConfig{testConsumerConfig{ts.URL}}}
+	now := time.Now()
+	claims, err := co.jwtClaims("jwtkey", now)
+	if err != nil {
+		t.Fatalf("failed to build jwt claims: %s", err.Error())
+	}
+	if claims.ExpiresAt != now.Unix()+3600 || claims.IssuedAt != now.Unix() || claims.NotBefore != now.Unix() {
+		t.Errorf("expected the token to be valid for an hour from now, got %+v", claims.StandardClaims)
+	}
+	if claims.ISS != "jwtkey" || claims.Audience != "edgex" || claims.Id == "" {
+		t.Errorf("expected iss, aud and jti to be set, got %+v", claims)
+	}
+	if len(claims.Groups) != 1 || claims.Groups[0] != "admin" {
+		t.Errorf("expected group admin in the claims, got %v instead", claims.Groups)
+	}
+
+	other, _ := co.jwtClaims("jwtkey", now)
+	if other.Id == claims.Id {
+		t.Errorf("expected every token to get a unique jti")
+	}
+}
@@ -0,0 +1,126 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConsumerInfo describes who can access the gateway through a consumer: its
+// ACL groups and the credentials it can authenticate with. Secrets are never included.
+type ConsumerInfo struct {
+	ID        string       `json:"id" yaml:"id"`
+	Username  string       `json:"username" yaml:"username"`
+	CreatedAt time.Time    `json:"created_at" yaml:"created_at"`
+	Groups    []string     `json:"groups" yaml:"groups"`
+	JWT       []JWTInfo    `json:"jwt" yaml:"jwt"`
+	OAuth2    []OAuth2Info `json:"oauth2" yaml:"oauth2"`
+}
+
+// JWTInfo describes a jwt credential of a consumer.
+type JWTInfo struct {
+	ID        string    `json:"id" yaml:"id"`
+	Key       string    `json:"key" yaml:"key"`
+	Algorithm string    `json:"algorithm" yaml:"algorithm"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// OAuth2Info describes an oauth2 application of a consumer.
+type OAuth2Info struct {
+	ID           string    `json:"id" yaml:"id"`
+	Name         string    `json:"name" yaml:"name"`
+	ClientID     string    `json:"client_id" yaml:"client_id"`
+	RedirectURIs []string  `json:"redirect_uris" yaml:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// Inspect reads the consumer together with its groups and credentials.
+func (c *Consumer) Inspect() (*ConsumerInfo, error) {
+	consumer, err := c.Show()
+	if err != nil {
+		return nil, err
+	}
+	return inspectConsumer(c.Connect, consumer)
+}
+
+// InspectConsumers inspects the consumers carrying tag, or every consumer when tag is empty.
+func InspectConsumers(connect Requestor, tag string) ([]ConsumerInfo, error) {
+	consumers, err := ListConsumers(connect, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ConsumerInfo, 0, len(consumers))
+	for i := range consumers {
+		info, err := inspectConsumer(connect, &consumers[i])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+func inspectConsumer(connect Requestor, consumer *KongConsumerResponse) (*ConsumerInfo, error) {
+	info := &ConsumerInfo{
+		ID:        consumer.ID,
+		Username:  consumer.Username,
+		CreatedAt: kongTime(consumer.CreatedAt),
+		Groups:    []string{},
+		JWT:       []JWTInfo{},
+		OAuth2:    []OAuth2Info{},
+	}
+	path := fmt.Sprintf("%s%s/", ConsumersPath, consumer.ID)
+
+	acls := []KongACLResponse{}
+	err := listKongObjects(connect, path+"acls", &acls)
+	if err != nil {
+		return nil, err
+	}
+	for _, acl := range acls {
+		info.Groups = append(info.Groups, acl.Group)
+	}
+
+	jwts := []KongJWTResponse{}
+	err = listKongObjects(connect, path+"jwt", &jwts)
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jwts {
+		info.JWT = append(info.JWT, JWTInfo{j.ID, j.Key, j.Algorithm, kongTime(j.CreatedAt)})
+	}
+
+	apps := []KongOAuth2Response{}
+	err = listKongObjects(connect, path+"oauth2", &apps)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		info.OAuth2 = append(info.OAuth2, OAuth2Info{app.ID, app.Name, app.ClientID, app.RedirectURIs, kongTime(app.CreatedAt)})
+	}
+	return info, nil
+}
+
+// kongTime converts a Kong timestamp, which is in milliseconds before Kong 1.0
+// and in seconds since, to a time.
+func kongTime(ts int64) time.Time {
+	if ts > 1e12 {
+		return time.Unix(0, ts*int64(time.Millisecond)).UTC()
+	}
+	return time.Unix(ts, 0).UTC()
+}
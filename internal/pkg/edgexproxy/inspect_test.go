@@ -0,0 +1,72 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("expected GET request, got %s instead", r.Method)
+		}
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.EscapedPath() {
+		case "/consumers/testuser":
+			w.Write([]byte(`{"id":"c1","username":"testuser","created_at":1546300800}`))
+		case "/consumers/c1/acls":
+			w.Write([]byte(`{"data":[{"id":"a1","group":"admin"},{"id":"a2","group":"user"}]}`))
+		case "/consumers/c1/jwt":
+			w.Write([]byte(`{"data":[{"id":"j1","key":"jwtkey","secret":"jwtsecret","algorithm":"HS256"}]}`))
+		case "/consumers/c1/oauth2":
+			w.Write([]byte(`{"data":[{"id":"o1","name":"edgex-kong","client_id":"testuser","client_secret":"testsecret"}]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	info, err := co.Inspect()
+	if err != nil {
+		t.Fatalf("failed to inspect consumer: %s", err.Error())
+	}
+	if len(info.Groups) != 2 || info.Groups[0] != "admin" {
+		t.Errorf("expected groups admin and user, got %v instead", info.Groups)
+	}
+	if len(info.JWT) != 1 || info.JWT[0].Key != "jwtkey" {
+		t.Errorf("expected jwt credential jwtkey, got %v instead", info.JWT)
+	}
+	if len(info.OAuth2) != 1 || info.OAuth2[0].ClientID != "testuser" {
+		t.Errorf("expected oauth2 client testuser, got %v instead", info.OAuth2)
+	}
+	if info.CreatedAt.Year() != 2019 {
+		t.Errorf("expected creation in 2019, got %s instead", info.CreatedAt)
+	}
+}
+
+func TestKongTime(t *testing.T) {
+	if kongTime(1546300800).Unix() != 1546300800 {
+		t.Errorf("expected a timestamp in seconds to be kept")
+	}
+	if kongTime(1546300800000).Unix() != 1546300800 {
+		t.Errorf("expected a timestamp in milliseconds to be converted")
+	}
+}
@@ -0,0 +1,220 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	jwt "github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const rsaKeyBits = 2048
+
+// jwtKey is the signing key of a consumer as stored in the secret service.
+// Only the public key is registered with the reverse proxy.
+type jwtKey struct {
+	Algorithm  string `json:"algorithm"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+}
+
+// isAsymmetricJWT reports whether tokens signed with alg are verified with a
+// public key instead of a secret shared with the reverse proxy.
+func isAsymmetricJWT(alg string) bool {
+	return alg == JWTAlgorithmRS256 || alg == JWTAlgorithmES256
+}
+
+// createSignedJWTToken signs a token with the private key of the consumer held in
+// the secret service. The key pair is generated the first time, and its public key
+// is registered as a jwt credential of the consumer unless it already is.
+func (c *Consumer) createSignedJWTToken(alg string) (string, error) {
+	key, err := c.jwtSigningKey(alg)
+	if err != nil {
+		return "", err
+	}
+	credKey, err := c.jwtPublicKeyCredential(key)
+	if err != nil {
+		return "", err
+	}
+	claims, err := c.jwtClaims(credKey, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var method jwt.SigningMethod
+	var priv crypto.PrivateKey
+	if alg == JWTAlgorithmES256 {
+		method = jwt.SigningMethodES256
+		priv, err = jwt.ParseECPrivateKeyFromPEM([]byte(key.PrivateKey))
+	} else {
+		method = jwt.SigningMethodRS256
+		priv, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s signing key of consumer %s with error %s", alg, c.Name, err.Error())
+	}
+	return jwt.NewWithClaims(method, claims).SignedString(priv)
+}
+
+// jwtSigningKey reads the signing key of the consumer from the secret service,
+// generating and storing a new one when there is none for alg yet.
+func (c *Consumer) jwtSigningKey(alg string) (*jwtKey, error) {
+	cs := c.secrets()
+	path := c.jwtKeyPath()
+	key := &jwtKey{}
+	found, err := cs.readSecret(path, key)
+	if err != nil {
+		return nil, err
+	}
+	if found && key.Algorithm == alg {
+		return key, nil
+	}
+	if found {
+		lc.Info(fmt.Sprintf("replacing %s signing key of consumer %s with a %s key", key.Algorithm, c.Name, alg))
+	}
+
+	key, err = generateJWTKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	err = cs.writeSecret(path, key)
+	if err != nil {
+		return nil, err
+	}
+	lc.Info(fmt.Sprintf("successful to generate %s signing key for consumer %s", alg, c.Name))
+	return key, nil
+}
+
+func (c *Consumer) jwtKeyPath() string {
+	return strings.TrimSuffix(c.Cfg.GetProxyJWTKeyPath(), "/") + "/" + c.Name
+}
+
+// jwtPublicKeyCredential returns the key of the jwt credential holding the public
+// key, registering the public key with the reverse proxy when needed. The other jwt
+// credentials of the consumer, such as an HS256 secret or an earlier key, are then
+// deleted so tokens signed with them stop verifying.
+func (c *Consumer) jwtPublicKeyCredential(key *jwtKey) (string, error) {
+	path := fmt.Sprintf("%s%s/jwt", ConsumersPath, c.Name)
+	creds := []KongJWTResponse{}
+	err := listKongObjects(c.Connect, path, &creds)
+	if err != nil {
+		return "", err
+	}
+	credKey := ""
+	for _, cred := range creds {
+		if cred.Algorithm == key.Algorithm && strings.TrimSpace(cred.RSAPublicKey) == strings.TrimSpace(key.PublicKey) {
+			credKey = cred.Key
+		}
+	}
+	if credKey == "" {
+		credKey, err = c.registerJWTPublicKey(path, key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, cred := range creds {
+		if cred.Key == credKey {
+			continue
+		}
+		lc.Info(fmt.Sprintf("deleting stale %s jwt credential %s of consumer %s", cred.Algorithm, cred.ID, c.Name))
+		err = (&Resource{cred.ID, c.Connect}).Remove(path + "/")
+		if err != nil {
+			return "", err
+		}
+	}
+	return credKey, nil
+}
+
+// registerJWTPublicKey adds a jwt credential holding the public key to the
+// consumer and returns its key.
+func (c *Consumer) registerJWTPublicKey(path string, key *jwtKey) (string, error) {
+	body := &KongJWTCredential{
+		Algorithm:    key.Algorithm,
+		RSAPublicKey: key.PublicKey,
+	}
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Post(path).BodyForm(body).Request()
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to register %s public key for consumer %s with error %s", key.Algorithm, c.Name, err.Error())
+		return "", errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		cred := JWTCred{}
+		json.NewDecoder(resp.Body).Decode(&cred)
+		lc.Info(fmt.Sprintf("successful to register %s public key for consumer %s", key.Algorithm, c.Name))
+		return cred.Key, nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	return "", fmt.Errorf("failed to register %s public key for consumer %s with error %s,%s", key.Algorithm, c.Name, resp.Status, string(b))
+}
+
+// generateJWTKey generates a PEM encoded RSA 2048 or ECDSA P-256 key pair for alg.
+func generateJWTKey(alg string) (*jwtKey, error) {
+	var priv *pem.Block
+	var pub interface{}
+	switch alg {
+	case JWTAlgorithmRS256:
+		k, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		priv = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+		pub = &k.PublicKey
+	case JWTAlgorithmES256:
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		priv = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		pub = &k.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported jwt signing algorithm %s", alg)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &jwtKey{
+		Algorithm:  alg,
+		PrivateKey: string(pem.EncodeToMemory(priv)),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, nil
+}
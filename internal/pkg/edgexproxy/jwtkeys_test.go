@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	jwt "github.com/dgrijalva/jwt-go"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateSignedJWTToken(t *testing.T) {
+	stored := jwtKey{}
+	registered := ""
+	deleted := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /v1/secret/edgex/jwt/testuser":
+			w.WriteHeader(http.StatusNotFound)
+		case "POST /v1/secret/edgex/jwt/testuser":
+			if r.Header.Get(VaultToken) != "test-token" {
+				t.Errorf("expected the vault token to be sent")
+			}
+			json.NewDecoder(r.Body).Decode(&stored)
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /consumers/testuser/jwt":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data":[{"id":"old","key":"hs256key","algorithm":"HS256"}]}`))
+		case "DELETE /consumers/testuser/jwt/old":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		case "POST /consumers/testuser/jwt":
+			if r.FormValue("algorithm") != JWTAlgorithmES256 {
+				t.Errorf("expected an ES256 credential, got %s instead", r.FormValue("algorithm"))
+			}
+			registered = r.FormValue("rsa_public_key")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"key":"es256key"}`))
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	signed, err := co.createSignedJWTToken(JWTAlgorithmES256)
+	if err != nil {
+		t.Fatalf("failed to create signed jwt token: %s", err.Error())
+	}
+	if stored.PrivateKey == "" || stored.PublicKey != registered {
+		t.Errorf("expected the private key in vault and the public key registered with kong")
+	}
+	if !deleted {
+		t.Errorf("expected the earlier HS256 credential to be deleted")
+	}
+
+	token, err := jwt.ParseWithClaims(signed, &KongJWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwt.ParseECPublicKeyFromPEM([]byte(registered))
+	})
+	if err != nil {
+		t.Fatalf("failed to verify signed jwt token: %s", err.Error())
+	}
+	if token.Header["alg"] != JWTAlgorithmES256 || token.Claims.(*KongJWTClaims).ISS != "es256key" {
+		t.Errorf("expected an ES256 token issued for es256key, got %v", token.Header)
+	}
+}
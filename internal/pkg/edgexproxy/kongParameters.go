@@ -19,44 +19,135 @@ package edgexproxy
 import jwt "github.com/dgrijalva/jwt-go"
 
 type KongService struct {
-	Name     string `url:"name,omitempty"`
-	Host     string `url:"host,omitempty"`
-	Port     string `url:"port,omitempty"`
-	Protocol string `url:"protocol,omitempty"`
+	Name     string   `url:"name,omitempty"`
+	Host     string   `url:"host,omitempty"`
+	Port     string   `url:"port,omitempty"`
+	Protocol string   `url:"protocol,omitempty"`
+	Path     string   `url:"path,omitempty"`
+	Tags     []string `url:"tags,omitempty"`
 }
 
 // KongServiceResponse is the response from Kong when creating a service
 type KongServiceResponse struct {
-	ID             string `json:"id,omitempty"`
-	CreatedAt      uint64 `json:"created_at,omitempty"`
-	UpdatedAt      uint64 `json:"updated_at,omitempty"`
-	ConnectTimeout int64  `json:"connect_timeout,omitempty"`
-	Protocol       string `json:"protocol,omitempty"`
-	Host           string `json:"host,omitempty"`
-	Port           uint64 `json:"port,omitempty"`
-	Path           string `json:"path,omitempty"`
-	Name           string `json:"name,omitempty"`
-	Retries        int64  `json:"retries,omitempty"`
-	ReadTimeout    int64  `json:"read_timeout,omitempty"`
-	WriteTimeout   int64  `json:"write_timeout,omitempty"`
+	ID             string   `json:"id,omitempty"`
+	CreatedAt      uint64   `json:"created_at,omitempty"`
+	UpdatedAt      uint64   `json:"updated_at,omitempty"`
+	ConnectTimeout int64    `json:"connect_timeout,omitempty"`
+	Protocol       string   `json:"protocol,omitempty"`
+	Host           string   `json:"host,omitempty"`
+	Port           uint64   `json:"port,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	Retries        int64    `json:"retries,omitempty"`
+	ReadTimeout    int64    `json:"read_timeout,omitempty"`
+	WriteTimeout   int64    `json:"write_timeout,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
 }
 
 type KongRoute struct {
-	Paths []string `json:"paths,omitempty"`
-	Name  string   `json:"name,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Service *Item    `json:"service,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// KongRouteResponse is the response from Kong when reading a route
+type KongRouteResponse struct {
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+	Service *Item    `json:"service,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// KongPluginResponse is the response from Kong when reading a plugin
+type KongPluginResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name,omitempty"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+	Service  *Item                  `json:"service,omitempty"`
+	Route    *Item                  `json:"route,omitempty"`
+	Consumer *Item                  `json:"consumer,omitempty"`
+	Tags     []string               `json:"tags,omitempty"`
 }
 
 type KongJWTPlugin struct {
-	Name string `url:"name,omitempty"`
+	Name           string   `url:"name,omitempty"`
+	ClaimsToVerify string   `url:"config.claims_to_verify,omitempty"`
+	Anonymous      string   `url:"config.anonymous"`
+	Tags           []string `url:"tags,omitempty"`
 }
 
 type KongOAuth2Plugin struct {
-	Name                    string `url:"name"`
-	Scope                   string `url:"config.scopes"`
-	MandatoryScope          string `url:"config.mandatory_scope"`
-	EnableClientCredentials string `url:"config.enable_client_credentials"`
-	EnableGlobalCredentials string `url:"config.global_credentials"`
-	TokenTTL                int    `url:"config.refresh_token_ttl"`
+	Name                    string   `url:"name"`
+	Scope                   string   `url:"config.scopes"`
+	MandatoryScope          string   `url:"config.mandatory_scope"`
+	EnableClientCredentials string   `url:"config.enable_client_credentials"`
+	EnableGlobalCredentials string   `url:"config.global_credentials"`
+	EnablePasswordGrant     string   `url:"config.enable_password_grant,omitempty"`
+	TokenTTL                int      `url:"config.token_expiration"`
+	RefreshTokenTTL         int      `url:"config.refresh_token_ttl"`
+	Anonymous               string   `url:"config.anonymous"`
+	Tags                    []string `url:"tags,omitempty"`
+}
+
+// KongConsumerResponse is the response from Kong when reading a consumer
+type KongConsumerResponse struct {
+	ID        string   `json:"id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	CustomID  string   `json:"custom_id,omitempty"`
+	CreatedAt int64    `json:"created_at,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// KongACLResponse is the response from Kong when reading the groups of a consumer
+type KongACLResponse struct {
+	ID        string `json:"id,omitempty"`
+	Group     string `json:"group,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// KongJWTResponse is the response from Kong when reading the jwt credentials
+// of a consumer, leaving out the secret
+type KongJWTResponse struct {
+	ID           string `json:"id,omitempty"`
+	Key          string `json:"key,omitempty"`
+	Algorithm    string `json:"algorithm,omitempty"`
+	RSAPublicKey string `json:"rsa_public_key,omitempty"`
+	CreatedAt    int64  `json:"created_at,omitempty"`
+}
+
+// KongOAuth2TokenResponse is the response from Kong when reading the oauth2
+// tokens, leaving out the tokens themselves
+type KongOAuth2TokenResponse struct {
+	ID         string `json:"id,omitempty"`
+	Credential *Item  `json:"credential,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+	ExpiresIn  int    `json:"expires_in,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// KongJWTCredential registers the public key verifying the RS256 or ES256
+// tokens of a consumer
+type KongJWTCredential struct {
+	Algorithm    string `url:"algorithm"`
+	RSAPublicKey string `url:"rsa_public_key"`
+}
+
+// KongOAuth2Response is the response from Kong when reading the oauth2
+// applications of a consumer, leaving out the client secret
+type KongOAuth2Response struct {
+	ID           string   `json:"id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	CreatedAt    int64    `json:"created_at,omitempty"`
+}
+
+type KongConsumer struct {
+	Tags []string `url:"tags,omitempty"`
 }
 
 type KongConsumerOauth2 struct {
@@ -67,32 +158,121 @@ type KongConsumerOauth2 struct {
 }
 
 type KongOuath2TokenRequest struct {
-	ClientID     string `url:"client_id,omitempty"`
-	ClientSecret string `url:"client_secret,omitempty"`
-	GrantType    string `url:"grant_type,omitempty"`
-	Scope        string `url:"scope,omitempty"`
+	ClientID            string `url:"client_id,omitempty"`
+	ClientSecret        string `url:"client_secret,omitempty"`
+	GrantType           string `url:"grant_type,omitempty"`
+	Scope               string `url:"scope,omitempty"`
+	ProvisionKey        string `url:"provision_key,omitempty"`
+	AuthenticatedUserID string `url:"authenticated_userid,omitempty"`
+	RefreshToken        string `url:"refresh_token,omitempty"`
 }
 
 type KongOauth2Token struct {
-	TokenType   string `json:"token_type"`
-	AccessToken string `json:"access_token"`
-	Expires     int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Expires      int    `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	ClientCert   string `json:"client_cert,omitempty"`
+	ClientKey    string `json:"client_key,omitempty"`
 }
 
 type KongACLPlugin struct {
-	Name      string `url:"name"`
-	WhiteList string `url:"config.whitelist"`
+	Name      string   `url:"name"`
+	WhiteList string   `url:"config.whitelist,omitempty"`
+	BlackList string   `url:"config.blacklist,omitempty"`
+	Tags      []string `url:"tags,omitempty" toml:"-"`
 }
 
 type KongBasicAuthPlugin struct {
-	Name            string `url:"name,omitempty"`
-	HideCredentials string `url:"config.hide_credentials,omitempty"`
+	Name            string   `url:"name,omitempty"`
+	HideCredentials string   `url:"config.hide_credentials,omitempty"`
+	Anonymous       string   `url:"config.anonymous"`
+	Tags            []string `url:"tags,omitempty"`
+}
+
+// KongRateLimitingPlugin caps the requests of each consumer. A window left
+// empty is sent as such so that Kong clears a limit that was dropped.
+type KongRateLimitingPlugin struct {
+	Name     string   `url:"name"`
+	Second   string   `url:"config.second"`
+	Minute   string   `url:"config.minute"`
+	Hour     string   `url:"config.hour"`
+	Month    string   `url:"config.month"`
+	Consumer string   `url:"consumer.id,omitempty"`
+	Tags     []string `url:"tags,omitempty"`
+}
+
+type KongKeyAuthPlugin struct {
+	Name            string   `url:"name,omitempty"`
+	KeyNames        string   `url:"config.key_names,omitempty"`
+	HideCredentials string   `url:"config.hide_credentials,omitempty"`
+	Anonymous       string   `url:"config.anonymous"`
+	Tags            []string `url:"tags,omitempty"`
+}
+
+type KongHMACAuthPlugin struct {
+	Name            string   `url:"name,omitempty"`
+	HideCredentials string   `url:"config.hide_credentials,omitempty"`
+	Anonymous       string   `url:"config.anonymous"`
+	Tags            []string `url:"tags,omitempty"`
+}
+
+type KongMTLSAuthPlugin struct {
+	Name           string   `url:"name,omitempty"`
+	CACertificates string   `url:"config.ca_certificates,omitempty"`
+	Anonymous      string   `url:"config.anonymous"`
+	Tags           []string `url:"tags,omitempty"`
+}
+
+// KongNodeInfo is the part of the root of the admin API telling the Kong version
+// and the plugins it can run.
+type KongNodeInfo struct {
+	Version string `json:"version"`
+	Plugins struct {
+		AvailableOnServer map[string]interface{} `json:"available_on_server"`
+	} `json:"plugins"`
+}
+
+type KongCACertificate struct {
+	Cert string   `json:"cert,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type KongCACertificateResponse struct {
+	ID   string   `json:"id"`
+	Cert string   `json:"cert"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type KongBasicAuthCredential struct {
+	Username string `url:"username,omitempty"`
+	Password string `url:"password,omitempty"`
+}
+
+type KongHMACAuthCredential struct {
+	Username string `url:"username,omitempty"`
+	Secret   string `url:"secret,omitempty"`
+}
+
+type KongKeyAuthResponse struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// KongCertificateResponse is a certificate as listed by Kong, with the SNIs it serves.
+type KongCertificateResponse struct {
+	ID   string   `json:"id"`
+	Cert string   `json:"cert"`
+	Snis []string `json:"snis"`
+	Tags []string `json:"tags,omitempty"`
 }
 
 type CertInfo struct {
 	Cert string   `json:"cert,omitempty"`
 	Key  string   `json:"key,omitempty"`
 	Snis []string `json:"snis,omitempty"`
+	Tags []string `json:"tags,omitempty"`
 }
 
 type JWTCred struct {
@@ -104,13 +284,15 @@ type JWTCred struct {
 }
 
 type KongJWTClaims struct {
-	ISS  string `json:"iss"`
-	Acct string `json:"account"`
+	ISS    string   `json:"iss"`
+	Acct   string   `json:"account"`
+	Groups []string `json:"groups,omitempty"`
 	jwt.StandardClaims
 }
 
 type Item struct {
-	ID string `json:"id"`
+	ID   string   `json:"id"`
+	Tags []string `json:"tags,omitempty"`
 }
 
 type DataCollect struct {
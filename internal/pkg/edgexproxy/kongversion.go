@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kongVersion is the release of Kong serving an admin API.
+type kongVersion struct {
+	Major int
+	Minor int
+}
+
+// kongNode is what a Kong admin API reported to the status check.
+type kongNode struct {
+	version kongVersion
+	plugins map[string]interface{}
+}
+
+// parseKongVersion reads the major and minor numbers of a version such as
+// 1.0.3 or 0.14.1-enterprise-edition.
+func parseKongVersion(version string) (kongVersion, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return kongVersion{}, fmt.Errorf("failed to parse Kong version %s", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return kongVersion{}, fmt.Errorf("failed to parse Kong version %s", version)
+	}
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil {
+		return kongVersion{}, fmt.Errorf("failed to parse Kong version %s", version)
+	}
+	return kongVersion{major, minor}, nil
+}
+
+func (v kongVersion) atLeast(major int, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v kongVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// recordKongNode keeps the version and plugins reported by the admin API of connect
+// on connect, if it keeps state.
+func recordKongNode(connect Requestor, info *KongNodeInfo) {
+	v, err := parseKongVersion(info.Version)
+	if err != nil {
+		lc.Warn(err.Error())
+		return
+	}
+	rs := stateOf(connect)
+	if rs == nil {
+		return
+	}
+	rs.Lock()
+	rs.kong = &kongNode{v, info.Plugins.AvailableOnServer}
+	rs.Unlock()
+	if !v.atLeast(1, 1) {
+		lc.Warn(fmt.Sprintf("Kong %s does not support tags, objects are created untagged and reconcile leaves objects that are no longer configured in place", info.Version))
+	}
+}
+
+// getKongNode returns the node recorded for the admin API of connect, if any.
+func getKongNode(connect Requestor) (kongNode, bool) {
+	rs := stateOf(connect)
+	if rs == nil {
+		return kongNode{}, false
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if rs.kong == nil {
+		return kongNode{}, false
+	}
+	return *rs.kong, true
+}
+
+// getKongVersion returns the version recorded for the admin API of connect, if any.
+func getKongVersion(connect Requestor) (kongVersion, bool) {
+	node, ok := getKongNode(connect)
+	return node.version, ok
+}
+
+// pluginAvailable reports whether Kong can run the plugin. A Kong that hasn't
+// reported its plugins is taken to have it.
+func pluginAvailable(connect Requestor, name string) bool {
+	node, ok := getKongNode(connect)
+	if !ok || node.plugins == nil {
+		return true
+	}
+	_, ok = node.plugins[name]
+	return ok
+}
+
+// tagsSupported reports whether Kong accepts tags on its objects, which came with
+// Kong 1.1. A Kong whose version hasn't been checked is taken to support them.
+func tagsSupported(connect Requestor) bool {
+	v, ok := getKongVersion(connect)
+	return !ok || v.atLeast(1, 1)
+}
+
+// managedTags are the Kong tags marking an object as created by edgexproxy, or
+// none when Kong does not support tags.
+func managedTags(connect Requestor, tag string) []string {
+	if !tagsSupported(connect) {
+		return nil
+	}
+	return []string{tag}
+}
@@ -0,0 +1,88 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseKongVersion(t *testing.T) {
+	tests := map[string]kongVersion{
+		"0.13.0":                    {0, 13},
+		"1.0.3":                     {1, 0},
+		"1.1.0rc1":                  {1, 1},
+		"0.14.1-enterprise-edition": {0, 14},
+	}
+	for version, expected := range tests {
+		v, err := parseKongVersion(version)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", version, err.Error())
+			continue
+		}
+		if v != expected {
+			t.Errorf("expected %s to parse as %s, got %s instead", version, expected, v)
+		}
+	}
+	if _, err := parseKongVersion("next"); err == nil {
+		t.Errorf("expected an invalid version to be refused")
+	}
+}
+
+func TestUntaggedOnOldKong(t *testing.T) {
+	tagged := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /":
+			w.Write([]byte(`{"version":"1.0.3"}`))
+		case "POST /services/":
+			r.ParseForm()
+			_, tagged = r.PostForm["tags"]
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&EdgeXRequestor{ProxyBaseURL: ts.URL, Client: &http.Client{}}, &testServiceCertCfg{}, &testServiceConfig{}}
+	if !tagsSupported(svc.Connect) {
+		t.Errorf("expected a Kong of unknown version to be taken to support tags")
+	}
+	err := svc.CheckProxyServiceStatus()
+	if err != nil {
+		t.Fatalf("failed to check proxy status: %s", err.Error())
+	}
+	if tagsSupported(svc.Connect) {
+		t.Errorf("expected Kong 1.0.3 not to support tags")
+	}
+	if !tagsSupported(&EdgeXRequestor{ProxyBaseURL: ts.URL}) {
+		t.Errorf("expected the Kong version to be kept by the requestor that checked it only")
+	}
+	err = svc.initKongService(&KongService{Name: "test", Host: "test", Tags: svc.managedTags()})
+	if err != nil {
+		t.Fatalf("failed to create service: %s", err.Error())
+	}
+	if tagged {
+		t.Errorf("expected the service to be created without tags")
+	}
+	err = svc.prune(nil, nil, nil)
+	if err != nil {
+		t.Errorf("expected prune to be skipped, got %s", err.Error())
+	}
+}
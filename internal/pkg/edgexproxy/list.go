@@ -0,0 +1,159 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KongPage is a single page of a Kong collection.
+type KongPage struct {
+	Data   []json.RawMessage `json:"data"`
+	Next   string            `json:"next"`
+	Offset string            `json:"offset"`
+}
+
+// KongPager iterates over every entity of a Kong collection, following the
+// next link of each page until the collection is exhausted.
+type KongPager struct {
+	Connect Requestor
+	path    string
+	next    string
+	page    []json.RawMessage
+	current json.RawMessage
+	err     error
+}
+
+func NewKongPager(connect Requestor, path string) *KongPager {
+	return &KongPager{Connect: connect, path: path, next: path}
+}
+
+// Next advances to the next entity, fetching the following page when needed.
+func (p *KongPager) Next() bool {
+	for len(p.page) == 0 {
+		if p.err != nil || p.next == "" {
+			return false
+		}
+		p.fetch()
+	}
+	p.current = p.page[0]
+	p.page = p.page[1:]
+	return true
+}
+
+// Decode unmarshals the current entity into v.
+func (p *KongPager) Decode(v interface{}) error {
+	err := json.Unmarshal(p.current, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode entry of %s with error %s", p.path, err.Error())
+	}
+	return nil
+}
+
+// Err returns the first error met while paging.
+func (p *KongPager) Err() error {
+	return p.err
+}
+
+func (p *KongPager) fetch() {
+	path := p.next
+	p.next = ""
+
+	req, err := sling.New().Base(p.Connect.GetProxyBaseURL()).Get(path).Request()
+	if err != nil {
+		p.err = err
+		return
+	}
+	resp, err := p.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		p.err = fmt.Errorf("failed to get list of %s with error %s", p.path, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		p.err = fmt.Errorf("failed to get list of %s with error %s,%s", p.path, resp.Status, string(b))
+		return
+	}
+
+	page := KongPage{}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		p.err = fmt.Errorf("failed to decode list of %s with error %s", p.path, err.Error())
+		return
+	}
+	p.page = page.Data
+	p.next, p.err = nextPagePath(p.Connect.GetProxyBaseURL(), p.path, &page)
+}
+
+// nextPagePath turns the next link of a page into a path relative to the admin
+// base URL, since Kong reports it with its own host name. The path of the base
+// URL is taken off the link, so an admin API behind a path prefix isn't asked for
+// the prefix twice. Kong versions that only return an offset are paged by adding
+// it to the original path.
+func nextPagePath(baseURL string, path string, page *KongPage) (string, error) {
+	if page.Next != "" {
+		u, err := url.Parse(page.Next)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse next page of %s with error %s", path, err.Error())
+		}
+		next := u.RequestURI()
+		if base, err := url.Parse(baseURL); err == nil {
+			if prefix := strings.TrimSuffix(base.Path, "/"); prefix != "" && strings.HasPrefix(next, prefix+"/") {
+				next = strings.TrimPrefix(next, prefix)
+			}
+		}
+		return strings.TrimPrefix(next, "/"), nil
+	}
+	if page.Offset != "" {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		return fmt.Sprintf("%s%soffset=%s", path, sep, url.QueryEscape(page.Offset)), nil
+	}
+	return "", nil
+}
+
+// listKongObjects decodes every entity of the Kong collection at path into v,
+// which must point to a slice.
+func listKongObjects(connect Requestor, path string, v interface{}) error {
+	items := [][]byte{}
+	p := NewKongPager(connect, path)
+	for p.Next() {
+		items = append(items, p.current)
+	}
+	if p.Err() != nil {
+		return p.Err()
+	}
+
+	all := append([]byte("["), bytes.Join(items, []byte(","))...)
+	all = append(all, ']')
+	err := json.Unmarshal(all, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode list of %s with error %s", path, err.Error())
+	}
+	return nil
+}
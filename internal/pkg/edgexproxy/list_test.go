@@ -0,0 +1,109 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKongPager(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("expected GET request, got %s instead", r.Method)
+		}
+		if r.URL.EscapedPath() != "/consumers" {
+			t.Errorf("expected request to /consumers, got %s instead", r.URL.EscapedPath())
+		}
+
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.Query().Get("offset") {
+		case "":
+			w.Write([]byte(`{"data":[{"id":"1"},{"id":"2"}],"next":"http://kong:8001/consumers?offset=page2","offset":"page2"}`))
+		case "page2":
+			w.Write([]byte(`{"data":[{"id":"3"}],"next":null}`))
+		default:
+			t.Errorf("unexpected offset %s", r.URL.Query().Get("offset"))
+		}
+	}))
+	defer ts.Close()
+
+	ids := []string{}
+	p := NewKongPager(&testServiceRequestor{ts.URL}, "consumers")
+	for p.Next() {
+		item := Item{}
+		err := p.Decode(&item)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		ids = append(ids, item.ID)
+	}
+	if p.Err() != nil {
+		t.Errorf("failed to page through consumers")
+		t.Error(p.Err().Error())
+	}
+	if len(ids) != 3 || ids[0] != "1" || ids[2] != "3" {
+		t.Errorf("expected consumers 1, 2 and 3, got %v instead", ids)
+	}
+}
+
+func TestNextPagePath(t *testing.T) {
+	tests := []struct {
+		base string
+		next string
+	}{
+		{"http://kong:8001/", "http://kong:8001/consumers?offset=page2"},
+		{"https://gateway/kong-admin/", "https://gateway/kong-admin/consumers?offset=page2"},
+		{"https://gateway/kong-admin/", "http://kong:8001/consumers?offset=page2"},
+	}
+	for _, test := range tests {
+		path, err := nextPagePath(test.base, "consumers", &KongPage{Next: test.next})
+		if err != nil || path != "consumers?offset=page2" {
+			t.Errorf("expected %s under %s to page consumers?offset=page2, got %s", test.next, test.base, path)
+		}
+	}
+}
+
+func TestKongPagerStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	p := NewKongPager(&testServiceRequestor{ts.URL}, "consumers")
+	if p.Next() {
+		t.Errorf("expected no entries when Kong rejects the request")
+	}
+	if p.Err() == nil {
+		t.Errorf("expected an error when Kong rejects the request")
+	}
+}
+
+func TestListKongObjectsDecodeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`not json`))
+	}))
+	defer ts.Close()
+
+	items := []Item{}
+	err := listKongObjects(&testServiceRequestor{ts.URL}, "consumers", &items)
+	if err == nil {
+		t.Errorf("expected an error for an undecodable list")
+	}
+}
@@ -0,0 +1,193 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultClientCertTTL is the lifetime of client certificates in seconds when
+// token_ttl is 0, since certificates can't be issued without an expiry.
+const defaultClientCertTTL = 365 * 24 * 60 * 60
+
+// mtlsAuth authenticates requests by a client certificate signed by the client
+// CA kept in the secret service. The reverse proxy maps the common name of the
+// certificate to the consumer of that name.
+type mtlsAuth struct{}
+
+// plugins refuses a Kong that can't run mtls-auth, which is a Kong Enterprise
+// plugin relying on the ca_certificates of Kong 1.3 or later.
+func (mtlsAuth) plugins(s *Service, anonymous string) ([]kongPlugin, error) {
+	if v, ok := getKongVersion(s.Connect); ok && (!v.atLeast(1, 3) || !pluginAvailable(s.Connect, AuthMethodMTLSAuth)) {
+		return nil, fmt.Errorf("%s needs Kong Enterprise 1.3 or later with the %s plugin, the reverse proxy runs Kong %s without it", AuthMethodMTLSAuth, AuthMethodMTLSAuth, v)
+	}
+	id, err := s.initClientCA()
+	if err != nil {
+		return nil, err
+	}
+	params := &KongMTLSAuthPlugin{
+		Name:           AuthMethodMTLSAuth,
+		CACertificates: id,
+		Anonymous:      anonymous,
+		Tags:           s.managedTags(),
+	}
+	return s.servicePlugins(AuthMethodMTLSAuth, params), nil
+}
+
+// issue generates a key pair and a certificate for it with the consumer name as
+// common name, signed by the client CA. The certificate is followed by the CA
+// certificate in the returned bundle.
+func (mtlsAuth) issue(c *Consumer, scopes []string) (*KongOauth2Token, error) {
+	ignoreScopes(AuthMethodMTLSAuth, scopes)
+	cp, err := c.secrets().getCertPairAt(c.Cfg.GetClientCAPath())
+	if err != nil {
+		return nil, err
+	}
+	caCert, caKey, err := parseCA(cp)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.Cfg.GetProxyAuthTTL()
+	if ttl <= 0 {
+		ttl = defaultClientCertTTL
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: c.Name},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(time.Duration(ttl) * time.Second),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign client certificate for consumer %s with error %s", c.Name, err.Error())
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &bytes.Buffer{}
+	pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	lc.Info(fmt.Sprintf("successful to issue client certificate %x for consumer %s", serial, c.Name))
+	return &KongOauth2Token{
+		TokenType:  "mtls",
+		Expires:    ttl,
+		ClientCert: bundle.String(),
+		ClientKey:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// revoke removes nothing: the reverse proxy keeps no credential for client
+// certificates, which stay valid until they expire.
+func (mtlsAuth) revoke(c *Consumer) (int, error) {
+	lc.Warn(fmt.Sprintf("client certificates of consumer %s can't be revoked and stay valid until they expire", c.Name))
+	return 0, nil
+}
+
+// initClientCA registers the certificate of the client CA with the reverse proxy
+// unless it already is, and returns its Kong id.
+func (s *Service) initClientCA() (string, error) {
+	path := s.CertCfg.GetClientCAPath()
+	cp, err := (&Certs{s.Connect, s.CertCfg}).getCertPairAt(path)
+	if err != nil {
+		return "", err
+	}
+	existing := []KongCACertificateResponse{}
+	err = listKongObjects(s.Connect, CACertificatesPath, &existing)
+	if err != nil {
+		return "", err
+	}
+	for _, ca := range existing {
+		if strings.TrimSpace(ca.Cert) == strings.TrimSpace(cp.Cert) {
+			lc.Info(fmt.Sprintf("client CA from %s has been registered", path))
+			return ca.ID, nil
+		}
+	}
+
+	body := &KongCACertificate{Cert: cp.Cert, Tags: s.managedTags()}
+	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(CACertificatesPath).BodyJSON(body).Request()
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to register client CA from %s with error %s", path, err.Error())
+		return "", errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		ca := KongCACertificateResponse{}
+		json.NewDecoder(resp.Body).Decode(&ca)
+		lc.Info(fmt.Sprintf("successful to register client CA from %s", path))
+		return ca.ID, nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to register client CA from %s with error %s,%s", path, resp.Status, string(b))
+	lc.Error(e)
+	return "", errors.New(e)
+}
+
+// parseCA decodes the PEM encoded certificate and private key of a CA.
+func parseCA(cp *CertPair) (*x509.Certificate, crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(cp.Cert))
+	if block == nil {
+		return nil, nil, errors.New("client CA certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse client CA certificate with error %s", err.Error())
+	}
+	if !cert.IsCA {
+		return nil, nil, errors.New("client CA certificate is not a CA certificate")
+	}
+
+	signer, err := parsePrivateKey(cp.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client CA key %s", err.Error())
+	}
+	return cert, signer, nil
+}
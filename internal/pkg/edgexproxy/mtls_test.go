@@ -0,0 +1,155 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMTLSServiceCertCfg struct {
+	testServiceCertCfg
+}
+
+func (tsc *testMTLSServiceCertCfg) GetTokenPath() string {
+	return "../../../test/test-resp-init.json"
+}
+
+func (tsc *testMTLSServiceCertCfg) GetClientCAPath() string {
+	return "v1/secret/edgex/clientca"
+}
+
+func testClientCA(t *testing.T) *CertPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "edgex-client-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err.Error())
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &CertPair{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+func TestIssueClientCert(t *testing.T) {
+	ca := testClientCA(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/v1/secret/edgex/clientca" || r.Header.Get(VaultToken) != "test-token" {
+			t.Errorf("unexpected request to %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(CertCollect{*ca})
+	}))
+	defer ts.Close()
+
+	co := &Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	token, err := mtlsAuth{}.issue(co, nil)
+	if err != nil {
+		t.Fatalf("failed to issue client certificate: %s", err.Error())
+	}
+	if token.ClientKey == "" || token.Expires != 3600 {
+		t.Errorf("expected a key and a certificate valid for an hour, got %+v", token)
+	}
+
+	block, rest := pem.Decode([]byte(token.ClientCert))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse client certificate: %s", err.Error())
+	}
+	if cert.Subject.CommonName != "testuser" {
+		t.Errorf("expected the consumer name as common name, got %s", cert.Subject.CommonName)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rest) {
+		t.Fatalf("expected the CA certificate to follow the client certificate")
+	}
+	_, err = cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestInitClientCA(t *testing.T) {
+	ca := testClientCA(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /v1/secret/edgex/clientca":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(CertCollect{*ca})
+		case "GET /ca_certificates/":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": []KongCACertificateResponse{{ID: "ca1", Cert: ca.Cert}}})
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := &Service{&testServiceRequestor{ts.URL}, &testMTLSServiceCertCfg{}, &testEdgeXServiceConfig{}}
+	plugins, err := mtlsAuth{}.plugins(svc, "")
+	if err != nil {
+		t.Fatalf("failed to set up client CA: %s", err.Error())
+	}
+	params, ok := plugins[0].Params.(*KongMTLSAuthPlugin)
+	if !ok || params.CACertificates != "ca1" {
+		t.Errorf("expected the registered client CA to be used, got %+v", plugins[0].Params)
+	}
+}
+
+func TestMTLSAuthUnsupported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/" {
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+		w.Write([]byte(`{"version":"1.0.3","plugins":{"available_on_server":{"jwt":true,"acl":true}}}`))
+	}))
+	defer ts.Close()
+
+	svc := &Service{&EdgeXRequestor{ProxyBaseURL: ts.URL, Client: &http.Client{}}, &testMTLSServiceCertCfg{}, &testServiceConfig{}}
+	err := svc.CheckProxyServiceStatus()
+	if err != nil {
+		t.Fatalf("failed to check proxy status: %s", err.Error())
+	}
+	_, err = mtlsAuth{}.plugins(svc, "")
+	if err == nil || !strings.Contains(err.Error(), "Kong Enterprise 1.3") {
+		t.Errorf("expected mtls-auth to be refused on Kong 1.0.3, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const oauth2SecretBytes = 32
+
+// OAuth2Client is the client of the oauth2 application of a consumer, as stored
+// in the secret service.
+type OAuth2Client struct {
+	ClientID     string `json:"client_id" yaml:"client_id"`
+	ClientSecret string `json:"client_secret" yaml:"client_secret"`
+}
+
+// OAuth2Client reads the oauth2 client of the consumer from the secret service.
+func (c *Consumer) OAuth2Client() (*OAuth2Client, error) {
+	client := &OAuth2Client{}
+	found, err := c.secrets().readSecret(c.oauth2SecretPath(), client)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no oauth2 client is stored for consumer %s", c.Name)
+	}
+	return client, nil
+}
+
+// RotateOAuth2Secret replaces the client secret of the oauth2 application of the
+// consumer with a new random one. The new secret is stored in the secret service
+// before Kong is updated, and the previous one is stored again when the update
+// fails, or the new one deleted when there was none, so the stored secret is
+// always one Kong accepts. Tokens issued with the
+// previous secret stay valid until they expire.
+func (c *Consumer) RotateOAuth2Secret() (*OAuth2Client, error) {
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	cs := c.secrets()
+	previous := &OAuth2Client{}
+	found, err := cs.readSecret(c.oauth2SecretPath(), previous)
+	if err != nil {
+		return nil, err
+	}
+	client := &OAuth2Client{ClientID: c.Name, ClientSecret: secret}
+	err = cs.writeSecret(c.oauth2SecretPath(), client)
+	if err != nil {
+		return nil, err
+	}
+	err = c.updateOAuth2Secret(client)
+	if err != nil {
+		var restoreErr error
+		if found {
+			restoreErr = cs.writeSecret(c.oauth2SecretPath(), previous)
+		} else {
+			restoreErr = cs.deleteSecret(c.oauth2SecretPath())
+		}
+		if restoreErr != nil {
+			lc.Error(fmt.Sprintf("failed to restore previous oauth2 client secret for consumer %s with error %s", c.Name, restoreErr.Error()))
+		}
+		return nil, err
+	}
+	lc.Info(fmt.Sprintf("successful to rotate oauth2 client secret for consumer %s", c.Name))
+	return client, nil
+}
+
+// oauth2ClientForToken returns the stored oauth2 client of the consumer, or a new
+// client with a random secret, stored right away, when there is none yet. created
+// reports whether the client is new.
+func (c *Consumer) oauth2ClientForToken() (client *OAuth2Client, created bool, err error) {
+	client = &OAuth2Client{}
+	found, err := c.secrets().readSecret(c.oauth2SecretPath(), client)
+	if err != nil || found {
+		return client, false, err
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, false, err
+	}
+	client = &OAuth2Client{ClientID: c.Name, ClientSecret: secret}
+	err = c.secrets().writeSecret(c.oauth2SecretPath(), client)
+	return client, true, err
+}
+
+// updateOAuth2Secret sets the client secret of the existing oauth2 application of the consumer.
+func (c *Consumer) updateOAuth2Secret(client *OAuth2Client) error {
+	path := fmt.Sprintf("%s%s/oauth2/%s", ConsumersPath, c.Name, url.PathEscape(client.ClientID))
+	body := &KongConsumerOauth2{ClientSecret: client.ClientSecret}
+	req, err := sling.New().Base(c.Connect.GetProxyBaseURL()).Patch(path).BodyForm(body).Request()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		e := fmt.Sprintf("failed to update oauth2 client secret for consumer %s with error %s", c.Name, err.Error())
+		return errors.New(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		lc.Info(fmt.Sprintf("successful to update oauth2 client secret for consumer %s", c.Name))
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("consumer %s has no oauth2 application %s", c.Name, client.ClientID)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to update oauth2 client secret for consumer %s with error %s,%s", c.Name, resp.Status, string(b))
+	lc.Error(e)
+	return errors.New(e)
+}
+
+func (c *Consumer) secrets() *Certs {
+	return &Certs{c.Connect, c.Cfg}
+}
+
+func (c *Consumer) oauth2SecretPath() string {
+	return strings.TrimSuffix(c.Cfg.GetProxyOAuth2SecretPath(), "/") + "/" + c.Name
+}
+
+// newClientSecret returns a random URL safe secret.
+func newClientSecret() (string, error) {
+	b := make([]byte, oauth2SecretBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate secret with error %s", err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
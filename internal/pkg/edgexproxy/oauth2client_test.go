@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRotateOAuth2Secret(t *testing.T) {
+	patched := ""
+	patchStatus := http.StatusOK
+	stored := OAuth2Client{ClientID: "testuser", ClientSecret: "old"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "PATCH /consumers/testuser/oauth2/testuser":
+			if stored.ClientSecret != r.FormValue("client_secret") {
+				t.Errorf("expected the new secret to be stored in vault before it is set on kong")
+			}
+			patched = r.FormValue("client_secret")
+			w.WriteHeader(patchStatus)
+		case "GET /v1/secret/edgex/oauth2/testuser":
+			if stored == (OAuth2Client{}) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": stored})
+		case "POST /v1/secret/edgex/oauth2/testuser":
+			json.NewDecoder(r.Body).Decode(&stored)
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE /v1/secret/edgex/oauth2/testuser":
+			stored = OAuth2Client{}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	client, err := co.RotateOAuth2Secret()
+	if err != nil {
+		t.Fatalf("failed to rotate oauth2 client secret: %s", err.Error())
+	}
+	if client.ClientSecret == "" || client.ClientSecret == "old" {
+		t.Errorf("expected a random client secret, got %q instead", client.ClientSecret)
+	}
+	if patched != client.ClientSecret || stored != *client {
+		t.Errorf("expected the new secret to be set on kong and stored in vault")
+	}
+
+	previous := stored
+	patchStatus = http.StatusInternalServerError
+	_, err = co.RotateOAuth2Secret()
+	if err == nil {
+		t.Fatalf("expected a failed kong update to fail the rotation")
+	}
+	if stored != previous {
+		t.Errorf("expected the previous secret to be stored again, got %+v", stored)
+	}
+
+	stored = OAuth2Client{}
+	_, err = co.RotateOAuth2Secret()
+	if err == nil {
+		t.Fatalf("expected a failed kong update to fail the rotation")
+	}
+	if stored != (OAuth2Client{}) {
+		t.Errorf("expected the new secret to be deleted when none was stored before, got %+v", stored)
+	}
+}
+
+func TestOAuth2Client(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/v1/secret/edgex/oauth2/testuser" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data":{"client_id":"testuser","client_secret":"s3cret"}}`))
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	client, err := co.OAuth2Client()
+	if err != nil {
+		t.Fatalf("failed to read oauth2 client: %s", err.Error())
+	}
+	if client.ClientSecret != "s3cret" {
+		t.Errorf("expected client secret s3cret, got %s instead", client.ClientSecret)
+	}
+
+	co.Name = "nobody"
+	_, err = co.OAuth2Client()
+	if err == nil {
+		t.Errorf("expected an error for a consumer without a stored client")
+	}
+}
@@ -0,0 +1,153 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// RefreshToken exchanges a refresh token of the consumer, issued for scopes, for
+// a new access token, which comes with a new refresh token.
+func (c *Consumer) RefreshToken(refreshToken string, scopes []string) (*KongOauth2Token, error) {
+	if !c.hasAuthMethod(AuthMethodOAuth2) {
+		return nil, fmt.Errorf("tokens can only be refreshed with oauth2 authentication")
+	}
+	resource, err := c.oauth2Resource(scopes)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.OAuth2Client()
+	if err != nil {
+		return nil, err
+	}
+	tokenreq := &KongOuath2TokenRequest{
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		GrantType:    OAuth2RefreshGrantType,
+		RefreshToken: refreshToken,
+	}
+	token, err := c.requestOAuth2Token(resource, tokenreq)
+	if err == nil && len(scopes) > 0 {
+		token.Scope = strings.Join(scopes, " ")
+	}
+	return token, err
+}
+
+// oauth2Resource returns the service whose token endpoint issues tokens for scopes.
+// Tokens of the default scope are issued by the configured resource.
+func (c *Consumer) oauth2Resource(scopes []string) (string, error) {
+	if len(scopes) == 0 || (len(scopes) == 1 && scopes[0] == OAuth2Scopes) {
+		return c.Cfg.GetProxyAuthResource(), nil
+	}
+	for _, svc := range c.Cfg.GetEdgeXSvcs() {
+		declared := map[string]bool{}
+		for _, scope := range svc.Scopes {
+			declared[scope] = true
+		}
+		matched := 0
+		for _, scope := range scopes {
+			if declared[scope] {
+				matched++
+			}
+		}
+		if matched == len(scopes) {
+			return svc.Name, nil
+		}
+		if matched > 0 {
+			return "", fmt.Errorf("scopes %s are not all declared by %s, a token is only valid for one service", strings.Join(scopes, ","), svc.Name)
+		}
+	}
+	return "", fmt.Errorf("no service declares the scopes %s", strings.Join(scopes, ","))
+}
+
+// requestOAuth2Token asks the token endpoint of the oauth2 plugin of resource for a token.
+// The request goes through the requestor's client, so it is recorded by a dry run and
+// the proxy certificate is verified like on every other call, and it is bounded by
+// httpTimeout whatever client the requestor hands out.
+func (c *Consumer) requestOAuth2Token(resource string, tokenreq *KongOuath2TokenRequest) (*KongOauth2Token, error) {
+	url := fmt.Sprintf("https://%s:%s/", c.Cfg.GetProxyServerName(), c.Cfg.GetProxyApplicationPortSSL())
+	path := fmt.Sprintf("%s/oauth2/token", resource)
+	lc.Info(fmt.Sprintf("requesting %s token on the endpoint of %s", tokenreq.GrantType, path))
+	req, err := sling.New().Base(url).Post(path).BodyForm(tokenreq).Request()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), httpTimeout)
+	defer cancel()
+	resp, err := c.Connect.GetHttpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		lc.Error(fmt.Sprintf("failed to create oauth2 token for client_id %s with error %s", tokenreq.ClientID, err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		token := &KongOauth2Token{}
+		err = json.NewDecoder(resp.Body).Decode(token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode oauth2 token with error %s", err.Error())
+		}
+		if token.Scope == "" {
+			token.Scope = tokenreq.Scope
+		}
+		lc.Info(fmt.Sprintf("successful on retrieving bearer credential for consumer %s", c.Name))
+		return token, nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to create bearer token for oauth authentication at endpoint oauth2/token with error %s,%s", resp.Status, string(b))
+	return nil, errors.New(e)
+}
+
+// oauth2ProvisionKey reads the provision key the password grant requires from the
+// oauth2 plugin protecting the resource service, falling back to a global plugin.
+func (c *Consumer) oauth2ProvisionKey(resource string) (string, error) {
+	svc := KongServiceResponse{}
+	_, err := getKongObject(c.Connect, ServicesPath+resource, &svc)
+	if err != nil {
+		return "", err
+	}
+	plugins := []KongPluginResponse{}
+	err = listKongObjects(c.Connect, PluginsPath, &plugins)
+	if err != nil {
+		return "", err
+	}
+
+	key := ""
+	for _, p := range plugins {
+		if p.Name != AuthMethodOAuth2 || p.Route != nil || p.Consumer != nil {
+			continue
+		}
+		k := pluginConfigValue(p.Config, "provision_key")
+		if p.Service != nil && p.Service.ID == svc.ID && svc.ID != "" {
+			return k, nil
+		}
+		if p.Service == nil {
+			key = k
+		}
+	}
+	if key == "" {
+		return "", fmt.Errorf("no oauth2 plugin protects %s", resource)
+	}
+	return key, nil
+}
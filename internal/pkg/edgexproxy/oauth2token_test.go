@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOAuth2ProvisionKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.EscapedPath() {
+		case "/services/all":
+			w.Write([]byte(`{"id":"s1","name":"all"}`))
+		case "/plugins/":
+			w.Write([]byte(`{"data":[{"id":"p1","name":"oauth2","config":{"provision_key":"globalkey"}},{"id":"p2","name":"oauth2","service":{"id":"s1"},"config":{"provision_key":"servicekey"}}]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	key, err := co.oauth2ProvisionKey("all")
+	if err != nil {
+		t.Fatalf("failed to read provision key: %s", err.Error())
+	}
+	if key != "servicekey" {
+		t.Errorf("expected the provision key of the service plugin, got %s instead", key)
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edgexproxy")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	tf := &TokenFileWriter{Filename: filepath.Join(dir, "accessToken.json")}
+	err = tf.SaveToken("testuser", &KongOauth2Token{AccessToken: "access", RefreshToken: "refresh", Expires: 7200, Scope: "coredata:read"})
+	if err != nil {
+		t.Fatalf("failed to save token: %s", err.Error())
+	}
+
+	saved, err := tf.LoadToken("testuser")
+	if err != nil || saved.RefreshToken != "refresh" || saved.Scope != "coredata:read" {
+		t.Errorf("expected refresh token refresh for coredata:read, got %+v instead", saved)
+	}
+	_, err = tf.LoadToken("otheruser")
+	if err == nil {
+		t.Errorf("expected an error for the token of another user")
+	}
+}
+
+func TestOAuth2Resource(t *testing.T) {
+	co := Consumer{"testuser", &testConsumerRequestor{""}, &testConsumerConfig{""}}
+	resource, err := co.oauth2Resource(nil)
+	if err != nil || resource != "all" {
+		t.Errorf("expected tokens of the default scope to be issued by the configured resource, got %s", resource)
+	}
+	resource, err = co.oauth2Resource([]string{"coredata:read"})
+	if err != nil || resource != "coredata" {
+		t.Errorf("expected coredata to issue coredata:read tokens, got %s", resource)
+	}
+	_, err = co.oauth2Resource([]string{"coredata:read", "metadata:read"})
+	if err == nil {
+		t.Errorf("expected scopes of different services to be rejected")
+	}
+	_, err = co.oauth2Resource([]string{"unknown"})
+	if err == nil {
+		t.Errorf("expected an undeclared scope to be rejected")
+	}
+}
@@ -0,0 +1,269 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// sensitiveFields are never copied from a request body into the plan.
+var sensitiveFields = map[string]bool{
+	"key":           true,
+	"secret":        true,
+	"client_secret": true,
+	"password":      true,
+	"private_key":   true,
+	"provision_key": true,
+	"refresh_token": true,
+}
+
+// maxPlanFieldLen keeps PEM material and other long values out of the printed plan.
+const maxPlanFieldLen = 64
+
+// PlanStep is a single call that init, reset or user management would make.
+type PlanStep struct {
+	Action   string            `json:"action"`
+	Target   string            `json:"target"`
+	Method   string            `json:"method"`
+	Resource string            `json:"resource"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// PlanRecorder is a Requestor for dry runs. Read-only requests are sent through the
+// wrapped Requestor while every other request is only recorded and answered with a
+// synthetic success response, so handler code runs unchanged without mutating Kong or Vault.
+type PlanRecorder struct {
+	Connect Requestor
+	Steps   []PlanStep
+	client  *http.Client
+}
+
+type planTransport struct {
+	recorder *PlanRecorder
+	next     http.RoundTripper
+}
+
+func (pr *PlanRecorder) GetProxyBaseURL() string {
+	return pr.Connect.GetProxyBaseURL()
+}
+
+func (pr *PlanRecorder) GetSecretSvcBaseURL() string {
+	return pr.Connect.GetSecretSvcBaseURL()
+}
+
+func (pr *PlanRecorder) state() *requestorState {
+	return stateOf(pr.Connect)
+}
+
+func (pr *PlanRecorder) GetHttpClient() *http.Client {
+	if pr.client == nil {
+		inner := pr.Connect.GetHttpClient()
+		next := inner.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		pr.client = &http.Client{Timeout: inner.Timeout, Transport: &planTransport{pr, next}}
+	}
+	return pr.client
+}
+
+func (pt *planTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	step, err := pt.recorder.record(req)
+	if err != nil {
+		return nil, err
+	}
+	if step.Action == "read" {
+		return pt.next.RoundTrip(req)
+	}
+
+	status := http.StatusOK
+	switch req.Method {
+	case http.MethodPost:
+		status = http.StatusCreated
+	case http.MethodDelete:
+		status = http.StatusNoContent
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+func (pr *PlanRecorder) record(req *http.Request) (PlanStep, error) {
+	step := PlanStep{
+		Action:   planAction(req.Method),
+		Target:   "kong",
+		Method:   req.Method,
+		Resource: strings.TrimPrefix(req.URL.Path, "/"),
+	}
+
+	u := req.URL.String()
+	if base := pr.Connect.GetSecretSvcBaseURL(); base != "" && strings.HasPrefix(u, base) {
+		step.Target = "vault"
+		if isVaultLogin(step.Resource) {
+			step.Action = "read"
+		}
+	}
+
+	if req.Body != nil && step.Action != "read" {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return step, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		step.Fields = planFields(req.Header.Get("Content-Type"), body)
+	}
+
+	if step.Action == "read" {
+		lc.Info(fmt.Sprintf("plan: reading %s %s", step.Target, step.Resource))
+	} else {
+		lc.Info(fmt.Sprintf("plan: skipping %s %s %s", step.Method, step.Target, step.Resource))
+	}
+	pr.Steps = append(pr.Steps, step)
+	return step, nil
+}
+
+// Summary counts the planned changes per action.
+func (pr *PlanRecorder) Summary() map[string]int {
+	summary := map[string]int{"create": 0, "update": 0, "delete": 0}
+	for _, step := range pr.Steps {
+		if step.Action != "read" {
+			summary[step.Action]++
+		}
+	}
+	return summary
+}
+
+// Print writes the plan in a human-readable form.
+func (pr *PlanRecorder) Print(w io.Writer) error {
+	summary := pr.Summary()
+	_, err := fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete\n", summary["create"], summary["update"], summary["delete"])
+	if err != nil {
+		return err
+	}
+	for _, step := range pr.Steps {
+		line := fmt.Sprintf("  %-7s %-6s %-7s %s", step.Action, step.Target, step.Method, step.Resource)
+		if len(step.Fields) > 0 {
+			keys := make([]string, 0, len(step.Fields))
+			for k := range step.Fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			pairs := make([]string, 0, len(keys))
+			for _, k := range keys {
+				pairs = append(pairs, fmt.Sprintf("%s=%s", k, step.Fields[k]))
+			}
+			line = fmt.Sprintf("%s (%s)", line, strings.Join(pairs, " "))
+		}
+		_, err = fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintJSON writes the plan as a JSON document.
+func (pr *PlanRecorder) PrintJSON(w io.Writer) error {
+	steps := pr.Steps
+	if steps == nil {
+		steps = []PlanStep{}
+	}
+	plan := struct {
+		Summary map[string]int `json:"summary"`
+		Steps   []PlanStep     `json:"steps"`
+	}{pr.Summary(), steps}
+
+	data, err := json.MarshalIndent(plan, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// isVaultLogin reports whether resource logs in to the secret service or renews
+// the login. These calls change nothing that is planned but are needed for the
+// token every other call to the secret service is made with, so they are sent as reads.
+func isVaultLogin(resource string) bool {
+	path := "/" + resource
+	return strings.Contains(path, "/v1/auth/") && (strings.HasSuffix(path, "/login") || strings.HasSuffix(path, "/renew-self"))
+}
+
+func planAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "read"
+	case http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "create"
+	}
+}
+
+// planFields flattens a form or JSON request body into printable fields, hiding secrets.
+func planFields(contentType string, body []byte) map[string]string {
+	fields := map[string]string{}
+	if strings.HasPrefix(contentType, "application/json") {
+		values := map[string]interface{}{}
+		if json.Unmarshal(body, &values) != nil {
+			return nil
+		}
+		for k, v := range values {
+			if b, err := json.Marshal(v); err == nil {
+				fields[k] = string(b)
+			}
+		}
+	} else {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil
+		}
+		for k, v := range values {
+			fields[k] = strings.Join(v, ",")
+		}
+	}
+
+	for k, v := range fields {
+		if sensitiveFields[k] {
+			fields[k] = "<redacted>"
+		} else if len(v) > maxPlanFieldLen {
+			fields[k] = fmt.Sprintf("<%d bytes>", len(v))
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,125 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("expected only GET requests to reach the server, got %s instead", r.Method)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	pr := &PlanRecorder{Connect: &testServiceRequestor{ts.URL}}
+	svc := Service{pr, &testServiceCertCfg{}, &testServiceConfig{}}
+	tk := &KongService{Name: "test", Host: "test", Port: "80", Protocol: "http"}
+	_, err := svc.reconcileKongService(tk)
+	if err != nil {
+		t.Errorf("failed to plan service")
+		t.Error(err.Error())
+	}
+
+	if len(pr.Steps) != 2 {
+		t.Fatalf("expected 2 recorded calls, got %d instead", len(pr.Steps))
+	}
+	if pr.Steps[0].Action != "read" || pr.Steps[1].Action != "create" {
+		t.Errorf("expected a read followed by a create, got %s and %s instead", pr.Steps[0].Action, pr.Steps[1].Action)
+	}
+	if pr.Steps[1].Fields["host"] != "test" {
+		t.Errorf("expected planned host to be test, got %s instead", pr.Steps[1].Fields["host"])
+	}
+	if pr.Summary()["create"] != 1 {
+		t.Errorf("expected 1 planned create, got %d instead", pr.Summary()["create"])
+	}
+
+	out := &bytes.Buffer{}
+	err = pr.Print(out)
+	if err != nil || !strings.HasPrefix(out.String(), "Plan: 1 to create, 0 to update, 0 to delete") {
+		t.Errorf("unexpected plan output %s", out.String())
+	}
+
+	out.Reset()
+	err = pr.PrintJSON(out)
+	if err != nil || !json.Valid(out.Bytes()) {
+		t.Errorf("expected plan to be valid JSON, got %s instead", out.String())
+	}
+}
+
+func TestPlanAppRoleLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approle")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	secretIDPath := filepath.Join(dir, "secret-id")
+	ioutil.WriteFile(secretIDPath, []byte("secret-id\n"), 0600)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "POST /v1/auth/approle/login", "POST /v1/auth/token/renew-self":
+			w.Write([]byte(`{"auth":{"client_token":"s.login","lease_duration":3600,"renewable":true}}`))
+		case "GET /v1/secret/data/edgex/test":
+			if r.Header.Get(VaultToken) != "s.login" {
+				t.Errorf("expected the login token, got %q", r.Header.Get(VaultToken))
+			}
+			w.Write([]byte(`{"data":{"data":{"cert":"test-cert","key":"test-key"},"metadata":{"version":1}}}`))
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	pr := &PlanRecorder{Connect: &EdgeXRequestor{SecretSvcBaseURL: ts.URL, Client: &http.Client{}}}
+	cs := Certs{pr, &testAppRoleCertCfg{testCertCfg{}, secretIDPath}}
+	cp := CertPair{}
+	found, err := cs.readSecret("v1/secret/edgex/test", &cp)
+	if err != nil || !found || cp.Cert != "test-cert" {
+		t.Fatalf("failed to read secret with approle login in a plan: %v", err)
+	}
+	err = cs.RenewToken()
+	if err != nil {
+		t.Errorf("failed to renew approle login in a plan: %s", err.Error())
+	}
+	for _, step := range pr.Steps {
+		if step.Action != "read" {
+			t.Errorf("expected logging in to be sent as a read, got %+v", step)
+		}
+	}
+}
+
+func TestPlanFieldsRedacted(t *testing.T) {
+	fields := planFields("application/x-www-form-urlencoded", []byte("client_id=user&client_secret=secret"))
+	if fields["client_id"] != "user" {
+		t.Errorf("expected client_id to be user, got %s instead", fields["client_id"])
+	}
+	if fields["client_secret"] != "<redacted>" {
+		t.Errorf("expected client_secret to be redacted, got %s instead", fields["client_secret"])
+	}
+}
@@ -0,0 +1,279 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"fmt"
+	"github.com/dghubble/sling"
+	"strconv"
+)
+
+// isSet reports whether the policy limits any window.
+func (r ratelimit) isSet() bool {
+	return r.Second > 0 || r.Minute > 0 || r.Hour > 0 || r.Month > 0
+}
+
+// problems lists what Kong would reject in the policy found at key. Limits can't
+// be negative and a longer window can't allow fewer requests than a shorter one.
+func (r ratelimit) problems(key string) []string {
+	problems := []string{}
+	windows := []struct {
+		name  string
+		limit int
+	}{{"second", r.Second}, {"minute", r.Minute}, {"hour", r.Hour}, {"month", r.Month}}
+	shorter, shorterName := 0, ""
+	for _, w := range windows {
+		if w.limit < 0 {
+			problems = append(problems, fmt.Sprintf("%s.%s can't be negative", key, w.name))
+			continue
+		}
+		if w.limit == 0 {
+			continue
+		}
+		if w.limit < shorter {
+			problems = append(problems, fmt.Sprintf("%s.%s can't be lower than %s.%s", key, w.name, key, shorterName))
+		}
+		shorter, shorterName = w.limit, w.name
+	}
+	return problems
+}
+
+// pluginParams configures the rate-limiting plugin enforcing the policy.
+func (r ratelimit) pluginParams(tags []string) *KongRateLimitingPlugin {
+	return &KongRateLimitingPlugin{
+		Name:   RateLimitingPlugin,
+		Second: rateLimitValue(r.Second),
+		Minute: rateLimitValue(r.Minute),
+		Hour:   rateLimitValue(r.Hour),
+		Month:  rateLimitValue(r.Month),
+		Tags:   tags,
+	}
+}
+
+func rateLimitValue(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return strconv.Itoa(limit)
+}
+
+// groupRateLimit combines the policies of the groups into the one applied to a
+// member. A consumer in several limited groups gets the lowest limit of each
+// window, so joining another group never lifts a limit.
+func groupRateLimit(groups []string, limits map[string]ratelimit) ratelimit {
+	combined := ratelimit{}
+	for _, g := range groups {
+		if limit, ok := limits[g]; ok {
+			combined = combined.lowest(limit)
+		}
+	}
+	return combined
+}
+
+// lowest returns the lowest limit of each window of both policies.
+func (r ratelimit) lowest(other ratelimit) ratelimit {
+	low := func(have int, limit int) int {
+		if limit > 0 && (have == 0 || limit < have) {
+			return limit
+		}
+		return have
+	}
+	return ratelimit{
+		Second: low(r.Second, other.Second),
+		Minute: low(r.Minute, other.Minute),
+		Hour:   low(r.Hour, other.Hour),
+		Month:  low(r.Month, other.Month),
+	}
+}
+
+// rateLimitPlugins lists the rate-limiting plugins of the global policy and of
+// the services with a policy of their own. The limit of a service also covers
+// the services created for its public paths.
+func (s *Service) rateLimitPlugins() []kongPlugin {
+	plugins := []kongPlugin{}
+	if global := s.ServiceCfg.GetProxyRateLimit(); global.isSet() {
+		plugins = append(plugins, kongPlugin{Name: RateLimitingPlugin, Params: global.pluginParams(s.managedTags())})
+	}
+
+	for _, svc := range s.ServiceCfg.GetEdgeXSvcs() {
+		if !svc.RateLimit.isSet() {
+			continue
+		}
+		params := svc.RateLimit.pluginParams(s.managedTags())
+		plugins = append(plugins, kongPlugin{Name: RateLimitingPlugin, Service: svc.Name, Params: params})
+		for _, route := range s.serviceRoutes(svc) {
+			if route.Service != nil {
+				plugins = append(plugins, kongPlugin{Name: RateLimitingPlugin, Service: route.Service.Name, Params: params})
+			}
+		}
+	}
+	return plugins
+}
+
+// initRateLimits installs the global and service rate limits and applies the
+// group rate limits to every consumer managed by edgexproxy.
+func (s *Service) initRateLimits() error {
+	for _, p := range s.rateLimitPlugins() {
+		err := s.initPlugin(p)
+		if err != nil {
+			return err
+		}
+	}
+	return s.applyGroupRateLimits()
+}
+
+// applyGroupRateLimits brings the rate limit of every consumer managed by
+// edgexproxy in line with the policies of its groups. The plugins of Kong are
+// listed once for all consumers.
+func (s *Service) applyGroupRateLimits() error {
+	consumers, err := ListConsumers(s.Connect, s.ServiceCfg.GetProxyManagedTag())
+	if err != nil || len(consumers) == 0 {
+		return err
+	}
+	plugins := []KongPluginResponse{}
+	err = listKongObjects(s.Connect, PluginsPath, &plugins)
+	if err != nil {
+		return err
+	}
+	for _, consumer := range consumers {
+		err = applyConsumerRateLimit(s.Connect, consumer, s.ServiceCfg.GetProxyGroupRateLimits(), s.managedTags(), plugins)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyGroupRateLimit brings the rate limit of the consumer in line with the
+// policies of the groups it currently belongs to.
+func (c *Consumer) ApplyGroupRateLimit() error {
+	return applyGroupRateLimit(c.Connect, c.Name, c.Cfg.GetProxyGroupRateLimits(), managedTags(c.Connect, c.Cfg.GetProxyManagedTag()))
+}
+
+// pluginRateLimit reads the policy of a rate-limiting plugin from its config.
+func pluginRateLimit(p *KongPluginResponse) ratelimit {
+	window := func(name string) int {
+		limit, _ := strconv.Atoi(pluginConfigValue(p.Config, name))
+		return limit
+	}
+	return ratelimit{Second: window("second"), Minute: window("minute"), Hour: window("hour"), Month: window("month")}
+}
+
+// consumerRateLimits returns the rate limits of a member of groups limited to
+// limit, by the Kong id of the service they apply to, or an empty id for the one
+// applying to every other service. Kong runs a plugin of the consumer in place of
+// the plugin of the service or the global one, so each limit is the lowest of the
+// group limit and the one of the rate-limiting plugins in plugins the consumer
+// would be held to without it.
+func consumerRateLimits(limit ratelimit, plugins []KongPluginResponse) map[string]ratelimit {
+	limits := map[string]ratelimit{}
+	if !limit.isSet() {
+		return limits
+	}
+	limits[""] = limit
+	for i, p := range plugins {
+		if p.Name != RateLimitingPlugin || p.Consumer != nil || p.Route != nil || p.Service != nil {
+			continue
+		}
+		limits[""] = limit.lowest(pluginRateLimit(&plugins[i]))
+	}
+	for i, p := range plugins {
+		if p.Name != RateLimitingPlugin || p.Consumer != nil || p.Route != nil || p.Service == nil {
+			continue
+		}
+		limits[p.Service.ID] = limit.lowest(pluginRateLimit(&plugins[i]))
+	}
+	return limits
+}
+
+// applyGroupRateLimit creates, updates or removes the rate-limiting plugins of the
+// consumer named name, see applyConsumerRateLimit.
+func applyGroupRateLimit(connect Requestor, name string, groupLimits map[string]ratelimit, tags []string) error {
+	consumer := KongConsumerResponse{}
+	found, err := getKongObject(connect, ConsumersPath+name, &consumer)
+	if err != nil || !found {
+		return err
+	}
+	plugins := []KongPluginResponse{}
+	err = listKongObjects(connect, PluginsPath, &plugins)
+	if err != nil {
+		return err
+	}
+	return applyConsumerRateLimit(connect, consumer, groupLimits, tags, plugins)
+}
+
+// applyConsumerRateLimit creates, updates or removes the rate-limiting plugins of
+// the consumer: one on every service with a limit of its own and one for the others.
+// plugins are all the plugins of Kong. Rate-limiting plugins of the consumer that
+// edgexproxy did not create are left alone.
+func applyConsumerRateLimit(connect Requestor, consumer KongConsumerResponse, groupLimits map[string]ratelimit, tags []string, plugins []KongPluginResponse) error {
+	name := consumer.Username
+	groups, err := consumerGroups(connect, name)
+	if err != nil {
+		return err
+	}
+	limits := consumerRateLimits(groupRateLimit(groups, groupLimits), plugins)
+
+	for _, p := range plugins {
+		if p.Name != RateLimitingPlugin || p.Route != nil || p.Consumer == nil || p.Consumer.ID != consumer.ID {
+			continue
+		}
+		scope := ""
+		if p.Service != nil {
+			scope = p.Service.ID
+		}
+		limit, wanted := limits[scope]
+		delete(limits, scope)
+		desc := rateLimitDescription(name, scope)
+		if !hasTags(p.Tags, tags) {
+			lc.Info(fmt.Sprintf("keeping the %s that was not set up by edgexproxy", desc))
+			continue
+		}
+		if !wanted {
+			lc.Info(fmt.Sprintf("removing %s, none of its groups is limited", desc))
+			err = (&Resource{p.ID, connect}).Remove(PluginsPath)
+		} else {
+			sl := sling.New().Base(connect.GetProxyBaseURL()).Patch(PluginsPath + p.ID)
+			err = sendKongRequest(connect, sl.BodyForm(limit.pluginParams(tags)), desc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for scope, limit := range limits {
+		path := fmt.Sprintf("%s%s/plugins", ConsumersPath, name)
+		params := limit.pluginParams(tags)
+		if scope != "" {
+			path = fmt.Sprintf("%s%s/plugins", ServicesPath, scope)
+			params.Consumer = consumer.ID
+		}
+		sl := sling.New().Base(connect.GetProxyBaseURL()).Post(path).BodyForm(params)
+		err = sendKongRequest(connect, sl, rateLimitDescription(name, scope))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rateLimitDescription(name string, serviceID string) string {
+	if serviceID == "" {
+		return fmt.Sprintf("rate limit for consumer %s", name)
+	}
+	return fmt.Sprintf("rate limit for consumer %s on service %s", name, serviceID)
+}
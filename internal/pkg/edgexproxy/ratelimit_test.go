@@ -0,0 +1,208 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testRateLimitServiceConfig struct {
+	testEdgeXServiceConfig
+}
+
+func (ts *testRateLimitServiceConfig) GetProxyRateLimit() ratelimit {
+	return ratelimit{Minute: 600}
+}
+
+func (ts *testRateLimitServiceConfig) GetEdgeXSvcs() map[string]service {
+	svcs := ts.testEdgeXServiceConfig.GetEdgeXSvcs()
+	coredata := svcs["coredata"]
+	coredata.RateLimit = ratelimit{Second: 10, Hour: 10000}
+	svcs["coredata"] = coredata
+	return svcs
+}
+
+type testRateLimitConsumerConfig struct {
+	testConsumerConfig
+}
+
+func (te *testRateLimitConsumerConfig) GetProxyGroupRateLimits() map[string]ratelimit {
+	return map[string]ratelimit{
+		"devices":   {Second: 5, Minute: 100},
+		"operators": {Minute: 60, Month: 100000},
+	}
+}
+
+func TestGroupRateLimit(t *testing.T) {
+	limits := (&testRateLimitConsumerConfig{}).GetProxyGroupRateLimits()
+	limit := groupRateLimit([]string{"user", "devices", "operators"}, limits)
+	if limit != (ratelimit{Second: 5, Minute: 60, Month: 100000}) {
+		t.Errorf("expected the lowest limit of each window, got %+v", limit)
+	}
+	if groupRateLimit([]string{"user"}, limits).isSet() {
+		t.Errorf("expected no limit for a consumer without limited groups")
+	}
+}
+
+func TestRateLimitPlugins(t *testing.T) {
+	s := &Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testRateLimitServiceConfig{}}
+	plugins := s.rateLimitPlugins()
+	if len(plugins) != 3 {
+		t.Fatalf("expected a global plugin and one for coredata and its open path, got %+v", plugins)
+	}
+	if plugins[0].path() != "plugins/" || plugins[0].Params.(*KongRateLimitingPlugin).Minute != "600" {
+		t.Errorf("expected the global limit to be installed globally, got %+v", plugins[0])
+	}
+	params := plugins[1].Params.(*KongRateLimitingPlugin)
+	if plugins[1].path() != "services/coredata/plugins" || params.Second != "10" || params.Minute != "" || params.Hour != "10000" {
+		t.Errorf("expected the coredata limit on its service, got %+v", params)
+	}
+	if plugins[2].Service != "coredata-open-api-v1-ping" {
+		t.Errorf("expected the coredata limit on its open path, got %+v", plugins[2])
+	}
+}
+
+func TestConsumerRateLimits(t *testing.T) {
+	plugins := []KongPluginResponse{
+		{Name: RateLimitingPlugin, Config: map[string]interface{}{"minute": float64(600)}},
+		{Name: RateLimitingPlugin, Service: &Item{ID: "s1"}, Config: map[string]interface{}{"second": float64(10), "hour": float64(10000)}},
+		{Name: RateLimitingPlugin, Service: &Item{ID: "s2"}, Consumer: &Item{ID: "c1"}, Config: map[string]interface{}{"second": float64(1)}},
+		{Name: ACLPlugin, Service: &Item{ID: "s3"}},
+	}
+	limits := consumerRateLimits(ratelimit{Second: 20, Minute: 100}, plugins)
+	if len(limits) != 2 {
+		t.Fatalf("expected a limit for every service and one for coredata, got %+v", limits)
+	}
+	if limits[""] != (ratelimit{Second: 20, Minute: 100}) {
+		t.Errorf("expected the group limit below the global one, got %+v", limits[""])
+	}
+	if limits["s1"] != (ratelimit{Second: 10, Minute: 100, Hour: 10000}) {
+		t.Errorf("expected the lowest of the group and service limits, got %+v", limits["s1"])
+	}
+	if len(consumerRateLimits(ratelimit{}, plugins)) != 0 {
+		t.Errorf("expected no limits for a consumer without limited groups")
+	}
+}
+
+func TestApplyGroupRateLimit(t *testing.T) {
+	groups := `{"data":[{"group":"devices"}]}`
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /consumers/testuser/acls":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(groups))
+		case "GET /consumers/testuser":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"c1","username":"testuser"}`))
+		case "GET /plugins/":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data":[` +
+				`{"id":"g1","name":"rate-limiting","config":{"minute":600}},` +
+				`{"id":"s1p","name":"rate-limiting","service":{"id":"s1"},"config":{"second":10,"hour":10000}},` +
+				`{"id":"p1","name":"rate-limiting","consumer":{"id":"c1"},"tags":["edgex-managed"]}]}`))
+		case "PATCH /plugins/p1":
+			if r.FormValue("config.second") != "5" || r.FormValue("config.minute") != "100" || r.FormValue("config.hour") != "" {
+				t.Errorf("expected the devices limit, got %v", r.Form)
+			}
+			w.WriteHeader(http.StatusOK)
+		case "POST /services/s1/plugins":
+			if r.FormValue("consumer.id") != "c1" || r.FormValue("config.second") != "5" || r.FormValue("config.hour") != "10000" {
+				t.Errorf("expected the lowest of the devices and service limits for the consumer, got %v", r.Form)
+			}
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE /plugins/p1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testRateLimitConsumerConfig{testConsumerConfig{ts.URL}}}
+	err := co.ApplyGroupRateLimit()
+	if err != nil {
+		t.Fatalf("failed to apply the group rate limit: %s", err.Error())
+	}
+	if !containsString(requests, "PATCH /plugins/p1") || !containsString(requests, "POST /services/s1/plugins") {
+		t.Errorf("expected the existing rate limit to be updated and one added for the limited service, got %v", requests)
+	}
+
+	groups = `{"data":[{"group":"user"}]}`
+	err = co.ApplyGroupRateLimit()
+	if err != nil {
+		t.Fatalf("failed to apply the group rate limit: %s", err.Error())
+	}
+	if requests[len(requests)-1] != "DELETE /plugins/p1" {
+		t.Errorf("expected the rate limit to be removed with the limited group, got %v", requests)
+	}
+}
+
+type testGroupRateLimitServiceConfig struct {
+	testServiceConfig
+}
+
+func (ts *testGroupRateLimitServiceConfig) GetProxyGroupRateLimits() map[string]ratelimit {
+	return (&testRateLimitConsumerConfig{}).GetProxyGroupRateLimits()
+}
+
+func TestApplyGroupRateLimits(t *testing.T) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /consumers/":
+			w.Write([]byte(`{"data":[{"id":"c1","username":"alice","tags":["edgex-managed"]},{"id":"c2","username":"bob","tags":["edgex-managed"]}]}`))
+		case "GET /consumers/alice/acls", "GET /consumers/bob/acls":
+			w.Write([]byte(`{"data":[{"group":"devices"}]}`))
+		case "GET /plugins/":
+			w.Write([]byte(`{"data":[{"id":"g1","name":"rate-limiting","config":{"minute":600}}]}`))
+		case "POST /consumers/alice/plugins", "POST /consumers/bob/plugins":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	s := &Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testGroupRateLimitServiceConfig{}}
+	err := s.applyGroupRateLimits()
+	if err != nil {
+		t.Fatalf("failed to apply the group rate limits: %s", err.Error())
+	}
+	listed := 0
+	for _, r := range requests {
+		if r == "GET /plugins/" {
+			listed++
+		}
+	}
+	if listed != 1 || !containsString(requests, "POST /consumers/alice/plugins") || !containsString(requests, "POST /consumers/bob/plugins") {
+		t.Errorf("expected the plugins to be listed once for both consumers, got %v", requests)
+	}
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
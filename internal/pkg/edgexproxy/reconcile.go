@@ -0,0 +1,499 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"github.com/google/go-querystring/query"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kongPlugin is a plugin that the configuration expects to find on Kong.
+// A plugin is attached to Route when set, otherwise to Service when set,
+// otherwise it is installed globally.
+type kongPlugin struct {
+	Name    string
+	Service string
+	Route   string
+	Params  interface{}
+}
+
+// path is where the plugin is created in its scope.
+func (p kongPlugin) path() string {
+	if p.Route != "" {
+		return fmt.Sprintf("%s%s/plugins", RoutesPath, p.Route)
+	} else if p.Service != "" {
+		return fmt.Sprintf("%s%s/plugins", ServicesPath, p.Service)
+	}
+	return PluginsPath
+}
+
+func (p kongPlugin) description() string {
+	if p.Route != "" {
+		return fmt.Sprintf("%s plugin for route %s", p.Name, p.Route)
+	} else if p.Service != "" {
+		return fmt.Sprintf("%s plugin for %s", p.Name, p.Service)
+	}
+	return fmt.Sprintf("%s plugin", p.Name)
+}
+
+// Reconcile converges Kong to the configuration. Unlike Init, objects that already
+// exist are compared against the configuration and updated when they have drifted,
+// and objects tagged as managed by edgexproxy that are no longer configured are removed.
+func (s *Service) Reconcile() error {
+	err := s.LoadCert()
+	if err != nil {
+		return err
+	}
+
+	services := map[string]string{}
+	routes := map[string]string{}
+	for _, service := range s.ServiceCfg.GetEdgeXSvcs() {
+		serviceParams := &KongService{
+			Name:     service.Name,
+			Host:     service.Host,
+			Port:     service.Port,
+			Protocol: service.Protocol,
+			Tags:     s.managedTags(),
+		}
+		id, err := s.reconcileKongService(serviceParams)
+		if err != nil {
+			return err
+		}
+		services[service.Name] = id
+
+		for _, route := range s.serviceRoutes(service) {
+			name, serviceID := service.Name, id
+			if route.Service != nil {
+				name = route.Service.Name
+				serviceID, err = s.reconcileKongService(route.Service)
+				if err != nil {
+					return err
+				}
+				services[name] = serviceID
+			}
+			routeID, err := s.reconcileKongRoute(route.Route, name, serviceID)
+			if err != nil {
+				return err
+			}
+			routes[route.Route.Name] = routeID
+		}
+	}
+
+	anonymous, err := s.initAnonymous()
+	if err != nil {
+		return err
+	}
+	plugins, err := s.desiredPlugins(anonymous)
+	if err != nil {
+		return err
+	}
+	current := []KongPluginResponse{}
+	err = listKongObjects(s.Connect, PluginsPath, &current)
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, p := range plugins {
+		id, err := s.reconcilePlugin(p, services, routes, current)
+		if err != nil {
+			return err
+		}
+		kept[id] = true
+	}
+
+	err = s.prune(services, routes, kept)
+	if err != nil {
+		return err
+	}
+	err = s.applyGroupRateLimits()
+	if err != nil {
+		return err
+	}
+
+	lc.Info("finishing reconciliation for reverse proxy")
+	return nil
+}
+
+// desiredPlugins lists the plugins Init installs for the current configuration,
+// given the id of the anonymous consumer.
+func (s *Service) desiredPlugins(anonymous string) ([]kongPlugin, error) {
+	plugins := []kongPlugin{}
+	for _, name := range s.ServiceCfg.GetProxyAuthMethods() {
+		m, err := lookupAuthMethod(name)
+		if err != nil {
+			return nil, err
+		}
+		methodPlugins, err := m.plugins(s, anonymous)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, methodPlugins...)
+	}
+	plugins = append(plugins, s.rateLimitPlugins()...)
+
+	for _, service := range s.ServiceCfg.GetEdgeXSvcs() {
+		acl := s.serviceACLParams(service)
+		if acl != nil {
+			plugins = append(plugins, kongPlugin{Name: acl.Name, Service: service.Name, Params: acl})
+		}
+		for _, route := range s.serviceRoutes(service) {
+			if route.ACL != nil {
+				plugins = append(plugins, kongPlugin{Name: route.ACL.Name, Route: route.Route.Name, Params: route.ACL})
+			}
+		}
+	}
+	return plugins, nil
+}
+
+// reconcileKongService creates or updates the service and returns its Kong id.
+// The id is empty when a dry run has only planned to create the service.
+func (s *Service) reconcileKongService(service *KongService) (string, error) {
+	current := KongServiceResponse{}
+	found, err := s.getKongObject(ServicesPath+service.Name, &current)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(ServicesPath).BodyForm(service)
+		err = sendKongRequestFor(s.Connect, sl, fmt.Sprintf("proxy service for %s", service.Name), &current)
+		return current.ID, err
+	}
+
+	if !serviceDrifted(service, &current) {
+		lc.Info(fmt.Sprintf("proxy service for %s is up to date", service.Name))
+		return current.ID, nil
+	}
+
+	lc.Info(fmt.Sprintf("proxy service for %s has drifted from the configuration", service.Name))
+	sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Patch(ServicesPath + service.Name).BodyForm(service)
+	return current.ID, s.sendKongRequest(sl, fmt.Sprintf("proxy service for %s", service.Name))
+}
+
+// reconcileKongRoute creates or updates the route and returns its Kong id.
+// The id is empty when a dry run has only planned to create the route.
+func (s *Service) reconcileKongRoute(route *KongRoute, name string, serviceID string) (string, error) {
+	current := KongRouteResponse{}
+	found, err := s.getKongObject(RoutesPath+route.Name, &current)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(ServicesPath + name + "/routes").BodyJSON(route)
+		err = sendKongRequestFor(s.Connect, sl, fmt.Sprintf("route %s for %s", route.Name, name), &current)
+		return current.ID, err
+	}
+
+	if !routeDrifted(route, &current, serviceID) {
+		lc.Info(fmt.Sprintf("route %s for %s is up to date", route.Name, name))
+		return current.ID, nil
+	}
+
+	lc.Info(fmt.Sprintf("route %s for %s has drifted from the configuration", route.Name, name))
+	body := *route
+	if serviceID != "" {
+		body.Service = &Item{ID: serviceID}
+	}
+	sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Patch(RoutesPath + route.Name).BodyJSON(&body)
+	return current.ID, s.sendKongRequest(sl, fmt.Sprintf("route %s for %s", route.Name, name))
+}
+
+// reconcilePlugin creates or updates the plugin and returns its Kong id, so that
+// prune keeps it. The id is empty when a dry run has only planned to create it.
+func (s *Service) reconcilePlugin(p kongPlugin, services map[string]string, routes map[string]string, current []KongPluginResponse) (string, error) {
+	desc := p.description()
+
+	for _, c := range current {
+		if c.Name != p.Name || !pluginInScope(p, &c, services, routes) {
+			continue
+		}
+		if !pluginDrifted(p, &c) {
+			lc.Info(fmt.Sprintf("%s is up to date", desc))
+			return c.ID, nil
+		}
+		lc.Info(fmt.Sprintf("%s has drifted from the configuration", desc))
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Patch(PluginsPath + c.ID).BodyForm(p.Params)
+		return c.ID, s.sendKongRequest(sl, desc)
+	}
+
+	created := KongPluginResponse{}
+	sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(p.path()).BodyForm(p.Params)
+	err := sendKongRequestFor(s.Connect, sl, desc, &created)
+	return created.ID, err
+}
+
+// prune removes the routes, plugins and services tagged as managed by edgexproxy
+// that the configuration no longer asks for. Nothing is removed when Kong does not
+// support tags, as objects created by hand can't be told apart.
+func (s *Service) prune(services map[string]string, keptRoutes map[string]string, plugins map[string]bool) error {
+	if !tagsSupported(s.Connect) {
+		lc.Info("skipping pruning as the reverse proxy does not support tags")
+		return nil
+	}
+	routes := []KongRouteResponse{}
+	err := listKongObjects(s.Connect, RoutesPath, &routes)
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		if _, ok := keptRoutes[r.Name]; ok || !hasTags(r.Tags, s.managedTags()) {
+			continue
+		}
+		lc.Info(fmt.Sprintf("pruning route %s", r.Name))
+		err = (&Resource{r.ID, s.Connect}).Remove(RoutesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	current := []KongPluginResponse{}
+	err = listKongObjects(s.Connect, PluginsPath, &current)
+	if err != nil {
+		return err
+	}
+	for _, p := range current {
+		// the plugins of consumers follow their groups and are kept in line
+		// with them after pruning
+		if plugins[p.ID] || p.Consumer != nil || !hasTags(p.Tags, s.managedTags()) {
+			continue
+		}
+		lc.Info(fmt.Sprintf("pruning %s plugin %s", p.Name, p.ID))
+		err = (&Resource{p.ID, s.Connect}).Remove(PluginsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	svcs := []KongServiceResponse{}
+	err = listKongObjects(s.Connect, ServicesPath, &svcs)
+	if err != nil {
+		return err
+	}
+	for _, svc := range svcs {
+		if _, ok := services[svc.Name]; ok || !hasTags(svc.Tags, s.managedTags()) {
+			continue
+		}
+		lc.Info(fmt.Sprintf("pruning proxy service %s", svc.Name))
+		err = (&Resource{svc.ID, s.Connect}).Remove(ServicesPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) getKongObject(path string, v interface{}) (bool, error) {
+	return getKongObject(s.Connect, path, v)
+}
+
+// getKongObject decodes the object at path into v and reports whether it exists.
+func getKongObject(connect Requestor, path string, v interface{}) (bool, error) {
+	req, err := sling.New().Base(connect.GetProxyBaseURL()).Get(path).Request()
+	if err != nil {
+		return false, err
+	}
+	resp, err := connect.GetHttpClient().Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s with error %s", path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to read %s with errorcode %d", path, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode %s with error %s", path, err.Error())
+	}
+	return true, nil
+}
+
+func (s *Service) sendKongRequest(sl *sling.Sling, desc string) error {
+	return sendKongRequest(s.Connect, sl, desc)
+}
+
+// sendKongRequest sends a request creating or updating the object described by desc.
+func sendKongRequest(connect Requestor, sl *sling.Sling, desc string) error {
+	return sendKongRequestFor(connect, sl, desc, nil)
+}
+
+// sendKongRequestFor is sendKongRequest decoding the object Kong answers with
+// into v when v is not nil. A dry run answers without a body and leaves v as is.
+func sendKongRequestFor(connect Requestor, sl *sling.Sling, desc string, v interface{}) error {
+	req, err := sl.Request()
+	if err != nil {
+		return err
+	}
+	resp, err := connect.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to set up %s with error %s", desc, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		lc.Info(fmt.Sprintf("successful to set up %s", desc))
+		if v != nil {
+			b, _ := ioutil.ReadAll(resp.Body)
+			if len(b) > 0 {
+				err = json.Unmarshal(b, v)
+				if err != nil {
+					return fmt.Errorf("failed to decode %s with error %s", desc, err.Error())
+				}
+			}
+		}
+		return nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to set up %s with error %s,%s", desc, resp.Status, string(b))
+	lc.Error(e)
+	return errors.New(e)
+}
+
+func serviceDrifted(want *KongService, got *KongServiceResponse) bool {
+	if want.Host != got.Host {
+		return true
+	}
+	if want.Port != "" && want.Port != strconv.FormatUint(got.Port, 10) {
+		return true
+	}
+	if want.Protocol != "" && want.Protocol != got.Protocol {
+		return true
+	}
+	if want.Path != "" && want.Path != got.Path {
+		return true
+	}
+	return !hasTags(got.Tags, want.Tags)
+}
+
+func routeDrifted(want *KongRoute, got *KongRouteResponse, serviceID string) bool {
+	if !sameStrings(want.Paths, got.Paths) || !sameStrings(want.Methods, got.Methods) {
+		return true
+	}
+	if serviceID != "" && (got.Service == nil || got.Service.ID != serviceID) {
+		return true
+	}
+	return !hasTags(got.Tags, want.Tags)
+}
+
+func pluginInScope(p kongPlugin, c *KongPluginResponse, services map[string]string, routes map[string]string) bool {
+	if c.Consumer != nil {
+		return false
+	}
+	if p.Route != "" {
+		return c.Route != nil && c.Route.ID == routes[p.Route]
+	}
+	if c.Route != nil {
+		return false
+	}
+	if p.Service == "" {
+		return c.Service == nil
+	}
+	return c.Service != nil && c.Service.ID == services[p.Service]
+}
+
+// pluginDrifted compares every config field the plugin would be created with
+// against the config Kong currently holds for it.
+func pluginDrifted(p kongPlugin, c *KongPluginResponse) bool {
+	values, err := query.Values(p.Params)
+	if err != nil {
+		return true
+	}
+	for key := range values {
+		if !strings.HasPrefix(key, "config.") {
+			continue
+		}
+		if values.Get(key) != pluginConfigValue(c.Config, strings.TrimPrefix(key, "config.")) {
+			return true
+		}
+	}
+	return !hasTags(c.Tags, values["tags"])
+}
+
+// pluginConfigValue renders the plugin config field at the dotted key the way
+// it is sent to Kong in a form body.
+func pluginConfigValue(config map[string]interface{}, key string) string {
+	var v interface{} = config
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[part]
+	}
+
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(t))
+		for _, item := range t {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// hasTags reports whether every tag in want is present in have.
+func hasTags(have []string, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
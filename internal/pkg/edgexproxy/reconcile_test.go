@@ -0,0 +1,426 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeKong is an in-memory Kong admin API. It keeps the objects created in each
+// collection, nested collections such as services/{name}/routes included, and
+// records every object deleted. The certificate at the cert path of
+// testRotateCertCfg is served as the Vault side.
+type fakeKong struct {
+	t       *testing.T
+	cert    *CertPair
+	objects map[string][]map[string]interface{}
+	deleted []string
+	next    int
+}
+
+func newFakeKong(t *testing.T) *fakeKong {
+	ca := testClientCA(t)
+	return &fakeKong{
+		t:       t,
+		cert:    testServerCert(t, ca, []string{"edgex-kong"}, time.Now().Add(time.Hour)),
+		objects: map[string][]map[string]interface{}{},
+	}
+}
+
+// parents are the field an object of a nested collection refers to its parent with.
+var fakeKongParents = map[string]string{"services": "service", "routes": "route", "consumers": "consumer"}
+
+func (k *fakeKong) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/"+(&testRotateCertCfg{}).GetCertPath() {
+		json.NewEncoder(w).Encode(CertCollect{*k.cert})
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	coll := parts[0]
+	switch {
+	case r.Method == "GET" && len(parts) == 1:
+		k.write(w, http.StatusOK, map[string]interface{}{"data": k.list(coll, "", "")})
+	case r.Method == "GET" && len(parts) == 3:
+		parent := k.find(coll, parts[1])
+		if parent == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		k.write(w, http.StatusOK, map[string]interface{}{"data": k.list(parts[2], fakeKongParents[coll], parent["id"].(string))})
+	case r.Method == "GET" && len(parts) == 2:
+		if o := k.find(coll, parts[1]); o != nil {
+			k.write(w, http.StatusOK, o)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "POST" && len(parts) == 1:
+		k.write(w, http.StatusCreated, k.create(coll, r, nil))
+	case r.Method == "POST" && len(parts) == 3:
+		parent := k.find(coll, parts[1])
+		if parent == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ref := map[string]interface{}{fakeKongParents[coll]: map[string]interface{}{"id": parent["id"]}}
+		k.write(w, http.StatusCreated, k.create(parts[2], r, ref))
+	case r.Method == "PUT" && len(parts) == 2:
+		if o := k.find(coll, parts[1]); o != nil {
+			k.write(w, http.StatusOK, o)
+			return
+		}
+		o := k.create(coll, r, nil)
+		o["username"] = parts[1]
+		k.write(w, http.StatusOK, o)
+	case r.Method == "PATCH" && len(parts) == 2:
+		if o := k.find(coll, parts[1]); o != nil {
+			k.write(w, http.StatusOK, o)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "DELETE" && len(parts) == 2:
+		o := k.find(coll, parts[1])
+		if o == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		k.deleted = append(k.deleted, fmt.Sprintf("%s %v", coll, o["name"]))
+		kept := []map[string]interface{}{}
+		for _, other := range k.objects[coll] {
+			if other["id"] != o["id"] {
+				kept = append(kept, other)
+			}
+		}
+		k.objects[coll] = kept
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		k.t.Errorf("unexpected %s request to %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (k *fakeKong) write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (k *fakeKong) find(coll string, key string) map[string]interface{} {
+	for _, o := range k.objects[coll] {
+		if o["id"] == key || o["name"] == key || o["username"] == key {
+			return o
+		}
+	}
+	return nil
+}
+
+func (k *fakeKong) list(coll string, parentField string, parentID string) []map[string]interface{} {
+	items := []map[string]interface{}{}
+	for _, o := range k.objects[coll] {
+		if parentField != "" {
+			ref, ok := o[parentField].(map[string]interface{})
+			if !ok || ref["id"] != parentID {
+				continue
+			}
+		}
+		items = append(items, o)
+	}
+	return items
+}
+
+// create stores the object sent as a form or JSON body, turning the dotted
+// config fields of a form into a nested config the way Kong does.
+func (k *fakeKong) create(coll string, r *http.Request, ref map[string]interface{}) map[string]interface{} {
+	o := map[string]interface{}{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		json.NewDecoder(r.Body).Decode(&o)
+	} else {
+		r.ParseForm()
+		config := map[string]interface{}{}
+		for key, values := range r.PostForm {
+			value := strings.Join(values, ",")
+			switch {
+			case strings.HasPrefix(key, "config."):
+				config[strings.TrimPrefix(key, "config.")] = value
+			case key == "tags":
+				o[key] = values
+			case key == "port":
+				o[key], _ = strconv.Atoi(value)
+			default:
+				o[key] = value
+			}
+		}
+		o["config"] = config
+	}
+	for field, value := range ref {
+		o[field] = value
+	}
+	k.next++
+	o["id"] = fmt.Sprintf("%s-%d", coll, k.next)
+	k.objects[coll] = append(k.objects[coll], o)
+	return o
+}
+
+// pluginsOf counts the plugins named name in Kong.
+func (k *fakeKong) pluginsOf(name string) int {
+	n := 0
+	for _, p := range k.objects["plugins"] {
+		if p["name"] == name {
+			n++
+		}
+	}
+	return n
+}
+
+type testReconcileServiceConfig struct {
+	testRotateServiceConfig
+}
+
+func (ts *testReconcileServiceConfig) GetProxyAuthMethods() []string {
+	return []string{AuthMethodJWT}
+}
+
+func (ts *testReconcileServiceConfig) GetProxyACLName() string {
+	return ACLPlugin
+}
+
+func (ts *testReconcileServiceConfig) GetProxyACLWhiteList() string {
+	return "admin"
+}
+
+func (ts *testReconcileServiceConfig) GetProxyRateLimit() ratelimit {
+	return ratelimit{Second: 10}
+}
+
+func (ts *testReconcileServiceConfig) GetEdgeXSvcs() map[string]service {
+	return map[string]service{
+		"coredata": {Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http"},
+	}
+}
+
+func TestReconcileKongServiceDrifted(t *testing.T) {
+	patched := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/services/test" {
+			t.Errorf("expected request to /services/test, got %s instead", r.URL.EscapedPath())
+		}
+		switch r.Method {
+		case "GET":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"1","name":"test","host":"old-host","port":80,"protocol":"http","tags":["edgex-managed"]}`))
+		case "PATCH":
+			patched = true
+			if r.FormValue("host") != "test" {
+				t.Errorf("expected host to be patched to test, got %s instead", r.FormValue("host"))
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("expected GET or PATCH request, got %s instead", r.Method)
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+	tk := &KongService{Name: "test", Host: "test", Port: "80", Protocol: "http", Tags: []string{DefaultManagedTag}}
+	id, err := svc.reconcileKongService(tk)
+	if err != nil {
+		t.Errorf("failed to reconcile service")
+		t.Error(err.Error())
+	}
+	if id != "1" {
+		t.Errorf("expected service id 1, got %s instead", id)
+	}
+	if !patched {
+		t.Errorf("expected drifted service to be patched")
+	}
+}
+
+func TestReconcileKongServiceMissing(t *testing.T) {
+	created := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.WriteHeader(http.StatusNotFound)
+		case "POST":
+			created = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("expected GET or POST request, got %s instead", r.Method)
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+	tk := &KongService{Name: "test", Host: "test", Port: "80", Protocol: "http"}
+	_, err := svc.reconcileKongService(tk)
+	if err != nil {
+		t.Errorf("failed to reconcile service")
+		t.Error(err.Error())
+	}
+	if !created {
+		t.Errorf("expected missing service to be created")
+	}
+}
+
+func TestPluginDrifted(t *testing.T) {
+	svc := Service{&testServiceRequestor{}, &testServiceCertCfg{}, &testServiceConfig{}}
+	p := kongPlugin{Name: "acl", Params: svc.aclPluginParams("acl", "admin")}
+	c := &KongPluginResponse{
+		Name:   "acl",
+		Config: map[string]interface{}{"whitelist": []interface{}{"admin"}},
+		Tags:   []string{DefaultManagedTag},
+	}
+	if pluginDrifted(p, c) {
+		t.Errorf("expected plugin with matching config not to drift")
+	}
+
+	c.Config["whitelist"] = []interface{}{"user"}
+	if !pluginDrifted(p, c) {
+		t.Errorf("expected plugin with a different whitelist to drift")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	deleted := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted[r.URL.EscapedPath()] = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.EscapedPath() {
+		case "/routes/":
+			w.Write([]byte(`{"data":[{"id":"r1","name":"test","tags":["edgex-managed"]},{"id":"r2","name":"stale","tags":["edgex-managed"]},{"id":"r3","name":"manual"}]}`))
+		case "/plugins/":
+			w.Write([]byte(`{"data":[{"id":"p1","name":"acl","tags":["edgex-managed"]},{"id":"p2","name":"jwt","tags":["edgex-managed"]}]}`))
+		case "/services/":
+			w.Write([]byte(`{"data":[{"id":"s1","name":"test","tags":["edgex-managed"]},{"id":"s2","name":"stale","tags":["edgex-managed"]},{"id":"s3","name":"manual"}]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+	err := svc.prune(map[string]string{"test": "s1"}, map[string]string{"test": "r1"}, map[string]bool{"p1": true})
+	if err != nil {
+		t.Errorf("failed to prune stale objects")
+		t.Error(err.Error())
+	}
+
+	expected := []string{"/routes/r2", "/plugins/p2", "/services/s2"}
+	for _, path := range expected {
+		if !deleted[path] {
+			t.Errorf("expected %s to be deleted", path)
+		}
+	}
+	if len(deleted) != len(expected) {
+		t.Errorf("expected %d objects to be deleted, got %d instead", len(expected), len(deleted))
+	}
+}
+
+func TestReconcileEmptyKong(t *testing.T) {
+	kong := newFakeKong(t)
+	ts := httptest.NewServer(kong)
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testRotateCertCfg{}, &testReconcileServiceConfig{}}
+	err := svc.Reconcile()
+	if err != nil {
+		t.Fatalf("failed to reconcile an empty Kong: %s", err.Error())
+	}
+	if len(kong.deleted) != 0 {
+		t.Errorf("expected nothing just created to be deleted, got %v", kong.deleted)
+	}
+	for _, name := range []string{AuthMethodJWT, ACLPlugin, RateLimitingPlugin} {
+		if kong.pluginsOf(name) != 1 {
+			t.Errorf("expected one %s plugin, got %d", name, kong.pluginsOf(name))
+		}
+	}
+
+	err = svc.Reconcile()
+	if err != nil {
+		t.Fatalf("failed to reconcile again: %s", err.Error())
+	}
+	if len(kong.deleted) != 0 || len(kong.objects["plugins"]) != 3 {
+		t.Errorf("expected a second reconcile to keep the plugins, deleted %v", kong.deleted)
+	}
+}
+
+type testReconcileRolesConfig struct {
+	testReconcileServiceConfig
+}
+
+func (ts *testReconcileRolesConfig) GetEdgeXSvcs() map[string]service {
+	return map[string]service{
+		"coredata": {
+			Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http",
+			Roles: []role{
+				{Methods: []string{"GET"}, Groups: []string{"reader", "admin"}},
+				{Methods: []string{"POST"}, Groups: []string{"admin"}},
+			},
+		},
+	}
+}
+
+func TestReconcileRouteACLs(t *testing.T) {
+	kong := newFakeKong(t)
+	ts := httptest.NewServer(kong)
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testRotateCertCfg{}, &testReconcileRolesConfig{}}
+	for i := 0; i < 2; i++ {
+		err := svc.Reconcile()
+		if err != nil {
+			t.Fatalf("failed to reconcile: %s", err.Error())
+		}
+	}
+	if len(kong.deleted) != 0 {
+		t.Errorf("expected nothing just created to be deleted, got %v", kong.deleted)
+	}
+
+	expected := map[string]string{"coredata-get": "admin,reader", "coredata-post": "admin"}
+	for name, whitelist := range expected {
+		route := kong.find("routes", name)
+		if route == nil {
+			t.Errorf("expected route %s to be created", name)
+			continue
+		}
+		acls := 0
+		for _, p := range kong.objects["plugins"] {
+			ref, ok := p["route"].(map[string]interface{})
+			if !ok || ref["id"] != route["id"] || p["name"] != ACLPlugin {
+				continue
+			}
+			acls++
+			config := p["config"].(map[string]interface{})
+			if config["whitelist"] != whitelist {
+				t.Errorf("expected route %s to whitelist %s, got %v", name, whitelist, config["whitelist"])
+			}
+		}
+		if acls != 1 {
+			t.Errorf("expected one acl plugin on route %s, got %d", name, acls)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package edgexproxy
 
 import (
 	"net/http"
+	"sync"
 )
 
 type Requestor interface {
@@ -30,6 +31,32 @@ type EdgeXRequestor struct {
 	ProxyBaseURL     string
 	SecretSvcBaseURL string
 	Client           *http.Client
+	requestorState
+}
+
+// requestorState is what a Requestor learns about the services it connects to and
+// its logins to the secret service, kept for as long as the Requestor is used.
+type requestorState struct {
+	sync.Mutex
+	kong  *kongNode
+	vault map[string]*vaultSession
+}
+
+func (rs *requestorState) state() *requestorState {
+	return rs
+}
+
+// statefulRequestor is a Requestor that keeps what it learns about its services.
+type statefulRequestor interface {
+	state() *requestorState
+}
+
+// stateOf returns the state kept by connect, or nil when connect keeps none.
+func stateOf(connect Requestor) *requestorState {
+	if sr, ok := connect.(statefulRequestor); ok {
+		return sr.state()
+	}
+	return nil
 }
 
 func (eq *EdgeXRequestor) GetProxyBaseURL() string {
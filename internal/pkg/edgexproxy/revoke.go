@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"fmt"
+	"time"
+)
+
+// TokenInfo describes an active oauth2 token of a consumer. The token itself is
+// never included.
+type TokenInfo struct {
+	ID        string     `json:"id" yaml:"id"`
+	Scope     string     `json:"scope" yaml:"scope"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// Tokens lists the oauth2 tokens issued to the applications of the consumer.
+func (c *Consumer) Tokens() ([]TokenInfo, error) {
+	if !c.hasAuthMethod(AuthMethodOAuth2) {
+		return nil, fmt.Errorf("tokens can only be listed with oauth2 authentication")
+	}
+	apps := []KongOAuth2Response{}
+	err := listKongObjects(c.Connect, fmt.Sprintf("%s%s/oauth2", ConsumersPath, c.Name), &apps)
+	if err != nil {
+		return nil, err
+	}
+	owned := map[string]bool{}
+	for _, app := range apps {
+		owned[app.ID] = true
+	}
+
+	tokens := []KongOAuth2TokenResponse{}
+	err = listKongObjects(c.Connect, OAuth2TokensPath, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	infos := []TokenInfo{}
+	for _, t := range tokens {
+		if t.Credential == nil || !owned[t.Credential.ID] {
+			continue
+		}
+		info := TokenInfo{ID: t.ID, Scope: t.Scope, CreatedAt: kongTime(t.CreatedAt)}
+		if t.ExpiresIn > 0 {
+			expires := info.CreatedAt.Add(time.Duration(t.ExpiresIn) * time.Second)
+			info.ExpiresAt = &expires
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// RevokeToken revokes the oauth2 token with the given id, which must belong to the consumer.
+func (c *Consumer) RevokeToken(id string) error {
+	tokens, err := c.Tokens()
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID == id {
+			return (&Resource{id, c.Connect}).Remove(OAuth2TokensPath)
+		}
+	}
+	return fmt.Errorf("consumer %s has no token %s", c.Name, id)
+}
+
+// RevokeTokens revokes every token of the consumer for each configured
+// authentication method and returns how many oauth2 tokens or credentials were
+// removed. The credentials of the other methods can't be revoked one by one, so
+// they are all deleted, and the next token create issues a new one.
+func (c *Consumer) RevokeTokens() (int, error) {
+	total := 0
+	for _, name := range c.Cfg.GetProxyAuthMethods() {
+		m, err := lookupAuthMethod(name)
+		if err != nil {
+			return total, err
+		}
+		n, err := m.revoke(c)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
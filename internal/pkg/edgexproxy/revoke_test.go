@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testOAuth2ConsumerConfig struct {
+	testConsumerConfig
+}
+
+func (te *testOAuth2ConsumerConfig) GetProxyAuthMethod() string {
+	return "oauth2"
+}
+
+func (te *testOAuth2ConsumerConfig) GetProxyAuthMethods() []string {
+	return []string{"oauth2"}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	deleted := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.EscapedPath() {
+		case "/consumers/testuser/oauth2":
+			w.Write([]byte(`{"data":[{"id":"app1","client_id":"testuser"}]}`))
+		case "/oauth2_tokens/":
+			w.Write([]byte(`{"data":[{"id":"t1","credential":{"id":"app1"},"scope":"all","expires_in":7200,"created_at":1546300800},{"id":"t2","credential":{"id":"other"}}]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testOAuth2ConsumerConfig{testConsumerConfig{ts.URL}}}
+	tokens, err := co.Tokens()
+	if err != nil {
+		t.Fatalf("failed to list tokens: %s", err.Error())
+	}
+	if len(tokens) != 1 || tokens[0].ID != "t1" || tokens[0].ExpiresAt.Unix() != 1546300800+7200 {
+		t.Errorf("expected token t1 expiring two hours after its creation, got %+v", tokens)
+	}
+
+	err = co.RevokeToken("t2")
+	if err == nil {
+		t.Errorf("expected the token of another consumer not to be revoked")
+	}
+	n, err := co.RevokeTokens()
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 token to be revoked, got %d", n)
+	}
+	if len(deleted) != 1 || deleted[0] != "/oauth2_tokens/t1" {
+		t.Errorf("expected only /oauth2_tokens/t1 to be deleted, got %v instead", deleted)
+	}
+}
+
+func TestRevokeJWTTokens(t *testing.T) {
+	deleted := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data":[{"id":"j1","key":"jwtkey"}]}`))
+	}))
+	defer ts.Close()
+
+	co := Consumer{"testuser", &testConsumerRequestor{ts.URL}, &testConsumerConfig{ts.URL}}
+	n, err := co.RevokeTokens()
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 jwt credential to be deleted, got %d", n)
+	}
+	if len(deleted) != 1 || deleted[0] != "/consumers/testuser/jwt/j1" {
+		t.Errorf("expected only /consumers/testuser/jwt/j1 to be deleted, got %v instead", deleted)
+	}
+}
@@ -0,0 +1,157 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// serviceRoute is a Kong route of an EdgeX service together with the acl
+// plugin attached to it, if any. Service is set when the route is served by
+// a Kong service of its own instead of the one of the EdgeX service.
+type serviceRoute struct {
+	Service *KongService
+	Route   *KongRoute
+	ACL     *KongACLPlugin
+}
+
+// roleMethods are the HTTP methods a role can grant.
+var roleMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
+// serviceRoutes returns the routes Init creates for a service, followed by its
+// public routes and its open public paths. A service without roles gets a single route for every method.
+// Otherwise the methods granted to the same groups share a route whose acl
+// whitelists those groups, and methods no role grants are not routed at all.
+func (s *Service) serviceRoutes(svc service) []serviceRoute {
+	if len(svc.Roles) == 0 {
+		routes := []serviceRoute{{
+			Route: &KongRoute{
+				Paths: []string{"/" + svc.Name},
+				Name:  svc.Name,
+				Tags:  s.managedTags(),
+			},
+		}}
+		routes = append(routes, s.publicRoutes(svc)...)
+		return append(routes, s.openRoutes(svc)...)
+	}
+
+	groupsByMethod := map[string]map[string]bool{}
+	for _, r := range svc.Roles {
+		for _, m := range r.Methods {
+			m = strings.ToUpper(m)
+			if groupsByMethod[m] == nil {
+				groupsByMethod[m] = map[string]bool{}
+			}
+			for _, g := range r.Groups {
+				groupsByMethod[m][g] = true
+			}
+		}
+	}
+
+	methodsByGroups := map[string][]string{}
+	for m, set := range groupsByMethod {
+		groups := []string{}
+		for g := range set {
+			groups = append(groups, g)
+		}
+		sort.Strings(groups)
+		key := strings.Join(groups, ",")
+		methodsByGroups[key] = append(methodsByGroups[key], m)
+	}
+	whitelists := []string{}
+	for key := range methodsByGroups {
+		whitelists = append(whitelists, key)
+	}
+	sort.Strings(whitelists)
+
+	routes := []serviceRoute{}
+	for _, whitelist := range whitelists {
+		methods := methodsByGroups[whitelist]
+		sort.Strings(methods)
+		routes = append(routes, serviceRoute{
+			Route: &KongRoute{
+				Paths:   []string{"/" + svc.Name},
+				Methods: methods,
+				Name:    svc.Name + "-" + strings.ToLower(strings.Join(methods, "-")),
+				Tags:    s.managedTags(),
+			},
+			ACL: s.aclPluginParams(ACLPlugin, whitelist),
+		})
+	}
+	routes = append(routes, s.publicRoutes(svc)...)
+	return append(routes, s.openRoutes(svc)...)
+}
+
+// openRoutes returns the routes of the public paths of a service, which no auth
+// or acl plugin is attached to.
+func (s *Service) openRoutes(svc service) []serviceRoute {
+	routes := []serviceRoute{}
+	for _, p := range svc.PublicPaths {
+		routes = append(routes, s.pathRoute(svc, "open", p))
+	}
+	return routes
+}
+
+// pathRoute returns a route for a single path of a service. The route gets a Kong
+// service of its own whose path is the one routed, so the service sees the same
+// path it would behind the route of the whole service, and plugins attached to
+// the service don't apply to it. Kong matches route paths by prefix, so the path
+// is routed as a regex anchored at its end and the paths below it stay behind the
+// route of the whole service.
+func (s *Service) pathRoute(svc service, kind string, p string) serviceRoute {
+	name := svc.Name + "-" + kind + strings.Replace(strings.TrimSuffix(p, "/"), "/", "-", -1)
+	return serviceRoute{
+		Service: &KongService{
+			Name:     name,
+			Host:     svc.Host,
+			Port:     svc.Port,
+			Protocol: svc.Protocol,
+			Path:     p,
+			Tags:     s.managedTags(),
+		},
+		Route: &KongRoute{
+			Paths: []string{regexp.QuoteMeta("/"+svc.Name+p) + "$"},
+			Name:  name,
+			Tags:  s.managedTags(),
+		},
+	}
+}
+
+// authServices returns the names of the Kong services the auth plugins are
+// attached to: every EdgeX service and the services of its public routes, but
+// not the ones of its open paths.
+func (s *Service) authServices() []string {
+	names := []string{}
+	for _, svc := range s.ServiceCfg.GetEdgeXSvcs() {
+		names = append(names, svc.Name)
+		for _, route := range s.publicRoutes(svc) {
+			names = append(names, route.Service.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
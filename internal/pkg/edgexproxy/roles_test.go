@@ -0,0 +1,72 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestServiceRoutes(t *testing.T) {
+	svc := Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testServiceConfig{}}
+
+	routes := svc.serviceRoutes(service{Name: "coredata"})
+	if len(routes) != 1 || routes[0].Route.Name != "coredata" || routes[0].ACL != nil {
+		t.Errorf("expected a single route without acl for a service without roles")
+	}
+
+	roles := []role{
+		{Groups: []string{"dashboards"}, Methods: []string{"get"}},
+		{Groups: []string{"integration"}, Methods: []string{"GET", "POST", "PUT", "DELETE"}},
+	}
+	routes = svc.serviceRoutes(service{Name: "coredata", Roles: roles})
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d instead", len(routes))
+	}
+
+	get := routes[0]
+	if get.Route.Name != "coredata-get" || get.ACL.WhiteList != "dashboards,integration" {
+		t.Errorf("expected route coredata-get for dashboards and integration, got %s for %s", get.Route.Name, get.ACL.WhiteList)
+	}
+	write := routes[1]
+	if write.Route.Name != "coredata-delete-post-put" || write.ACL.WhiteList != "integration" {
+		t.Errorf("expected route coredata-delete-post-put for integration, got %s for %s", write.Route.Name, write.ACL.WhiteList)
+	}
+	if !sameStrings(write.Route.Methods, []string{"DELETE", "POST", "PUT"}) {
+		t.Errorf("expected methods DELETE, POST and PUT, got %v instead", write.Route.Methods)
+	}
+
+	routes = svc.serviceRoutes(service{Name: "coredata", Roles: roles, PublicPaths: []string{"/api/v1/ping"}})
+	open := routes[len(routes)-1]
+	if len(routes) != 3 || open.ACL != nil || open.Route.Paths[0] != "/coredata/api/v1/ping$" {
+		t.Errorf("expected an open route for /coredata/api/v1/ping without acl, got %+v", open)
+	}
+	if open.Service == nil || open.Service.Name != "coredata-open-api-v1-ping" || open.Service.Path != "/api/v1/ping" {
+		t.Errorf("expected a service of its own for the open route, got %+v", open.Service)
+	}
+
+	// Kong anchors regex paths at the start of the request path
+	re := regexp.MustCompile("^" + open.Route.Paths[0])
+	if !re.MatchString("/coredata/api/v1/ping") {
+		t.Errorf("expected the open route to match its path")
+	}
+	for _, p := range []string{"/coredata/api/v1/pingX", "/coredata/api/v1/ping/event"} {
+		if re.MatchString(p) {
+			t.Errorf("expected the open route not to match %s", p)
+		}
+	}
+}
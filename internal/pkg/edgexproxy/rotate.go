@@ -0,0 +1,170 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// certConfirmAttempts bounds how long ConfirmCert waits for the reverse proxy to
+// serve a new certificate, as Kong workers only pick up changes periodically.
+var (
+	certConfirmAttempts = 6
+	certConfirmDelay    = 5 * time.Second
+)
+
+// certFingerprint returns the SHA-256 fingerprint of the certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// RotateCert replaces the certificate on the reverse proxy with the one stored in
+// the secret service unless every SNI is already served with it. The Kong
+// certificates serving the SNIs are updated in place, see uploadCert. It returns
+// the fingerprint of the certificate in the secret service and whether it was
+// uploaded.
+func (s *Service) RotateCert() (string, bool, error) {
+	cp, err := s.getCertPair()
+	if err != nil {
+		return "", false, err
+	}
+	leaf, err := s.checkCert(cp)
+	if err != nil {
+		return "", false, err
+	}
+	fingerprint := certFingerprint(leaf)
+
+	current, err := s.currentCerts()
+	if err != nil {
+		return fingerprint, false, err
+	}
+	if s.servesCert(current, fingerprint) {
+		lc.Info(fmt.Sprintf("certificate with serial %s is already on the reverse proxy", leaf.SerialNumber.Text(16)))
+		return fingerprint, false, nil
+	}
+	for _, c := range current {
+		certs, err := parseCertificates(c.Cert)
+		if err == nil {
+			lc.Info(fmt.Sprintf("replacing certificate with serial %s by serial %s", certs[0].SerialNumber.Text(16), leaf.SerialNumber.Text(16)))
+		}
+	}
+	return fingerprint, true, s.uploadCert(cp, current)
+}
+
+// servesCert reports whether the Kong certificates serve every SNI, and only
+// SNIs of the configuration, with the certificate of the given fingerprint.
+func (s *Service) servesCert(current []KongCertificateResponse, fingerprint string) bool {
+	wanted := map[string]bool{}
+	for _, sni := range s.snis() {
+		wanted[sni] = true
+	}
+	held := 0
+	for _, c := range current {
+		certs, err := parseCertificates(c.Cert)
+		if err != nil || certFingerprint(certs[0]) != fingerprint {
+			return false
+		}
+		for _, sni := range c.Snis {
+			if !wanted[sni] {
+				return false
+			}
+			held++
+		}
+	}
+	return held == len(wanted)
+}
+
+// ConfirmCert checks that the SSL port of the reverse proxy serves the certificate
+// with the given fingerprint, waiting for Kong to pick up a new certificate.
+func (s *Service) ConfirmCert(fingerprint string) error {
+	url := fmt.Sprintf("https://%s/", net.JoinHostPort(s.ServiceCfg.GetProxyServerName(), s.ServiceCfg.GetProxyApplicationPortSSL()))
+	for attempt := 1; ; attempt++ {
+		served, err := s.servedFingerprint(url)
+		if err == nil && served == fingerprint {
+			lc.Info(fmt.Sprintf("confirmed %s serves certificate %s", url, fingerprint))
+			return nil
+		}
+		if attempt >= certConfirmAttempts {
+			if err != nil {
+				return fmt.Errorf("failed to confirm the certificate served on %s with error %s", url, err.Error())
+			}
+			return fmt.Errorf("%s still serves certificate %s instead of %s", url, served, fingerprint)
+		}
+		time.Sleep(certConfirmDelay)
+	}
+}
+
+// servedFingerprint returns the fingerprint of the certificate served at url. Each
+// attempt uses a new connection, a kept alive one would show the old certificate.
+func (s *Service) servedFingerprint(url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Close = true
+	resp, err := s.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return "", errors.New("no certificate was served")
+	}
+	return certFingerprint(resp.TLS.PeerCertificates[0]), nil
+}
+
+// WatchCert checks the certificate in the secret service every interval and
+// rotates the one on the reverse proxy whenever it changes, until stop is closed.
+// The token of the secret service is renewed along the way. Failures are logged
+// and retried at the next check, so a sealed secret service or a broken
+// certificate doesn't end the watch.
+func (s *Service) WatchCert(interval time.Duration, stop <-chan struct{}) {
+	certs := &Certs{s.Connect, s.CertCfg}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lc.Info(fmt.Sprintf("watching certificate on path %s every %s", s.CertCfg.GetCertPath(), interval))
+	for {
+		err := certs.RenewToken()
+		if err != nil {
+			lc.Error(fmt.Sprintf("failed to renew secret service token with error %s", err.Error()))
+		}
+		fingerprint, changed, err := s.RotateCert()
+		if err == nil && changed {
+			err = s.ConfirmCert(fingerprint)
+		}
+		if err != nil {
+			lc.Error(fmt.Sprintf("failed to rotate certificate with error %s", err.Error()))
+		}
+
+		select {
+		case <-stop:
+			lc.Info("stopped watching certificate")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRotateCertCfg struct {
+	testServiceCertCfg
+}
+
+func (tsc *testRotateCertCfg) GetCertPath() string {
+	return "v1/secret/edgex/pki/tls/edgex-kong"
+}
+
+func (tsc *testRotateCertCfg) GetTokenPath() string {
+	return "../../../test/test-resp-init.json"
+}
+
+type testRotateServiceConfig struct {
+	testServiceConfig
+	proxySSL string
+}
+
+func (ts *testRotateServiceConfig) GetSecretSvcSNIS() string {
+	return "edgex-kong"
+}
+
+func (ts *testRotateServiceConfig) GetProxyServerName() string {
+	host, _, _ := net.SplitHostPort(ts.proxySSL)
+	return host
+}
+
+func (ts *testRotateServiceConfig) GetProxyApplicationPortSSL() string {
+	_, port, _ := net.SplitHostPort(ts.proxySSL)
+	return port
+}
+
+type testTLSRequestor struct {
+	testServiceRequestor
+	client *http.Client
+}
+
+func (tr *testTLSRequestor) GetHttpClient() *http.Client {
+	return tr.client
+}
+
+func TestRotateCert(t *testing.T) {
+	ca := testClientCA(t)
+	cp := testServerCert(t, ca, []string{"edgex-kong"}, time.Now().Add(time.Hour))
+	served := testServerCert(t, ca, []string{"edgex-kong"}, time.Now().Add(time.Hour))
+	patched := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /v1/secret/edgex/pki/tls/edgex-kong":
+			json.NewEncoder(w).Encode(CertCollect{*cp})
+		case "GET /certificates/":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []KongCertificateResponse{{ID: "c1", Cert: served.Cert, Snis: []string{"edgex-kong"}}},
+			})
+		case "PATCH /certificates/c1":
+			body := CertInfo{}
+			json.NewDecoder(r.Body).Decode(&body)
+			patched = body.Cert
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := &Service{&testServiceRequestor{ts.URL}, &testRotateCertCfg{}, &testRotateServiceConfig{}}
+	fingerprint, changed, err := svc.RotateCert()
+	if err != nil || !changed {
+		t.Fatalf("expected the certificate to be rotated, got %v", err)
+	}
+	if patched != cp.Cert {
+		t.Errorf("expected the existing Kong certificate to be updated in place")
+	}
+	if len(fingerprint) != 64 {
+		t.Errorf("expected a SHA-256 fingerprint, got %s", fingerprint)
+	}
+
+	served = cp
+	patched = ""
+	_, changed, err = svc.RotateCert()
+	if err != nil || changed || patched != "" {
+		t.Errorf("expected a certificate already served not to be uploaded again, got %v", err)
+	}
+}
+
+func TestConfirmCert(t *testing.T) {
+	defer func(attempts int) { certConfirmAttempts = attempts }(certConfirmAttempts)
+	certConfirmAttempts = 1
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	requestor := &testTLSRequestor{testServiceRequestor{""}, ts.Client()}
+	cfg := &testRotateServiceConfig{proxySSL: strings.TrimPrefix(ts.URL, "https://")}
+	svc := &Service{requestor, &testRotateCertCfg{}, cfg}
+	err := svc.ConfirmCert(certFingerprint(ts.Certificate()))
+	if err != nil {
+		t.Errorf("expected the served certificate to be confirmed: %s", err.Error())
+	}
+	err = svc.ConfirmCert("0123")
+	if err == nil || !strings.Contains(err.Error(), "still serves") {
+		t.Errorf("expected another certificate to be reported, got %v", err)
+	}
+}
+
+type testSplitSNIServiceConfig struct {
+	testRotateServiceConfig
+}
+
+func (ts *testSplitSNIServiceConfig) GetSecretSvcSNIS() string {
+	return "edgex-kong,kong.local"
+}
+
+func TestRotateCertSplitSNIs(t *testing.T) {
+	ca := testClientCA(t)
+	cp := testServerCert(t, ca, []string{"edgex-kong", "kong.local"}, time.Now().Add(time.Hour))
+	old := testServerCert(t, ca, []string{"edgex-kong", "kong.local"}, time.Now().Add(time.Hour))
+	patched := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /v1/secret/edgex/pki/tls/edgex-kong":
+			json.NewEncoder(w).Encode(CertCollect{*cp})
+		case "GET /certificates/":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []KongCertificateResponse{
+					{ID: "c1", Cert: old.Cert, Snis: []string{"edgex-kong"}},
+					{ID: "c2", Cert: old.Cert, Snis: []string{"kong.local", "other.example"}},
+					{ID: "c3", Cert: old.Cert, Snis: []string{"unrelated.example"}},
+				},
+			})
+		case "PATCH /certificates/c1", "PATCH /certificates/c2":
+			body := CertInfo{}
+			json.NewDecoder(r.Body).Decode(&body)
+			patched = append(patched, fmt.Sprintf("%s %s %v", r.URL.EscapedPath(), strings.Join(body.Snis, ","), body.Cert == cp.Cert))
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	svc := &Service{&testServiceRequestor{ts.URL}, &testRotateCertCfg{}, &testSplitSNIServiceConfig{}}
+	_, changed, err := svc.RotateCert()
+	if err != nil || !changed {
+		t.Fatalf("expected the certificate to be rotated, got %v", err)
+	}
+	expected := []string{
+		"/certificates/c2 other.example false",
+		"/certificates/c1 edgex-kong,kong.local true",
+	}
+	if strings.Join(patched, ";") != strings.Join(expected, ";") {
+		t.Errorf("expected kong.local to be moved onto the updated certificate, got %v", patched)
+	}
+}
@@ -17,11 +17,15 @@
 package edgexproxy
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/dghubble/sling"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type Service struct {
@@ -31,24 +35,41 @@ type Service struct {
 }
 
 type ServiceConfig interface {
-	GetProxyAuthMethod() string
+	GetProxyAuthMethods() []string
+	GetProxyAnonymous() string
+	GetProxyPublicRoutes() []string
 	GetProxyAuthTTL() int
+	GetProxyRefreshTTL() int
 	GetProxyAuthResource() string
+	GetProxyRateLimit() ratelimit
+	GetProxyGroupRateLimits() map[string]ratelimit
 	GetProxyACLName() string
 	GetProxyACLWhiteList() string
+	GetProxyManagedTag() string
 	GetSecretSvcSNIS() string
+	GetCACertPath() string
+	GetProxyServerName() string
+	GetProxyApplicationPortSSL() string
 	GetEdgeXSvcs() map[string]service
 }
 
+// CheckProxyServiceStatus checks that the admin API is up and records the Kong
+// version and plugins it reports, so objects are only tagged when Kong supports
+// tags and unsupported authentication methods are refused.
 func (s *Service) CheckProxyServiceStatus() error {
-	return s.checkServiceStatus(s.Connect.GetProxyBaseURL())
+	info := KongNodeInfo{}
+	err := s.checkServiceStatus(s.Connect.GetProxyBaseURL(), &info)
+	if err == nil && info.Version != "" {
+		recordKongNode(s.Connect, &info)
+	}
+	return err
 }
 
 func (s *Service) CheckSecretServiceStatus() error {
-	return s.checkServiceStatus(s.Connect.GetSecretSvcBaseURL())
+	return s.checkServiceStatus(s.Connect.GetSecretSvcBaseURL(), nil)
 }
 
-func (s *Service) checkServiceStatus(path string) error {
+func (s *Service) checkServiceStatus(path string, v interface{}) error {
 	req, err := sling.New().Get(path).Request()
 	resp, err := s.Connect.GetHttpClient().Do(req)
 	if err != nil {
@@ -59,6 +80,9 @@ func (s *Service) checkServiceStatus(path string) error {
 
 	if resp.StatusCode == http.StatusOK {
 		lc.Info(fmt.Sprintf("the service on %s is up successfully", path))
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
 		return nil
 	}
 
@@ -66,14 +90,31 @@ func (s *Service) checkServiceStatus(path string) error {
 	return errors.New(e)
 }
 
-func (s *Service) ResetProxy() error {
+// ResetProxy removes the routes, services, consumers, plugins and certificates
+// tagged as managed by edgexproxy, along with the client CA when mtls-auth is
+// configured. When all is set every object is removed regardless of its tags.
+// A Kong without tags is only reset when all is set, as the objects created by
+// edgexproxy can't be told apart from the others.
+func (s *Service) ResetProxy(all bool) error {
+	if !all && !tagsSupported(s.Connect) {
+		v, _ := getKongVersion(s.Connect)
+		return fmt.Errorf("Kong %s does not support tags, so the objects created by edgexproxy can't be told apart from others, pass --all to remove every object", v)
+	}
 	paths := []string{RoutesPath, ServicesPath, ConsumersPath, PluginsPath, CertificatesPath}
+	for _, name := range s.ServiceCfg.GetProxyAuthMethods() {
+		if name == AuthMethodMTLSAuth {
+			paths = append(paths, CACertificatesPath)
+		}
+	}
 	for _, path := range paths {
 		d, err := s.getSvcIDs(path)
 		if err != nil {
 			return err
 		}
 		for _, c := range d.Section {
+			if !all && !hasTags(c.Tags, s.managedTags()) {
+				continue
+			}
 			r := &Resource{c.ID, s.Connect}
 			err = r.Remove(path)
 			if err != nil {
@@ -85,7 +126,7 @@ func (s *Service) ResetProxy() error {
 }
 
 func (s *Service) Init() error {
-	err := s.loadCert()
+	err := s.LoadCert()
 	if err != nil {
 		return err
 	}
@@ -96,6 +137,7 @@ func (s *Service) Init() error {
 			Host:     service.Host,
 			Port:     service.Port,
 			Protocol: service.Protocol,
+			Tags:     s.managedTags(),
 		}
 
 		err := s.initKongService(serviceParams)
@@ -103,22 +145,53 @@ func (s *Service) Init() error {
 			return err
 		}
 
-		routeParams := &KongRoute{
-			Paths: []string{"/" + service.Name},
-			Name:  service.Name,
+		for _, route := range s.serviceRoutes(service) {
+			name := service.Name
+			if route.Service != nil {
+				err = s.initKongService(route.Service)
+				if err != nil {
+					return err
+				}
+				name = route.Service.Name
+			}
+			err = s.initKongRoutes(route.Route, name)
+			if err != nil {
+				return err
+			}
+			if route.ACL == nil {
+				continue
+			}
+			err = s.initRouteACL(route.Route.Name, route.ACL)
+			if err != nil {
+				return err
+			}
 		}
-		err = s.initKongRoutes(routeParams, service.Name)
+	}
+
+	anonymous, err := s.initAnonymous()
+	if err != nil {
+		return err
+	}
+	for _, name := range s.ServiceCfg.GetProxyAuthMethods() {
+		err = s.initAuthmethod(name, anonymous)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = s.initAuthmethod(s.ServiceCfg.GetProxyAuthMethod(), s.ServiceCfg.GetProxyAuthTTL())
-	if err != nil {
-		return err
+	for _, service := range s.ServiceCfg.GetEdgeXSvcs() {
+		aclParams := s.serviceACLParams(service)
+		if aclParams == nil {
+			lc.Info(fmt.Sprintf("no acl configured for %s, every consumer can reach it", service.Name))
+			continue
+		}
+		err = s.initServiceACL(service.Name, aclParams)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = s.initACL(s.ServiceCfg.GetProxyACLName(), s.ServiceCfg.GetProxyACLWhiteList())
+	err = s.initRateLimits()
 	if err != nil {
 		return err
 	}
@@ -127,32 +200,153 @@ func (s *Service) Init() error {
 	return nil
 }
 
-func (s *Service) loadCert() error {
+// LoadCert uploads the certificate pair stored in the secret service to the reverse
+// proxy. A pair that fails checkCertPair is refused so the certificate on the
+// reverse proxy is left in place.
+func (s *Service) LoadCert() error {
 	cp, err := s.getCertPair()
 	if err != nil {
 		return err
 	}
-	body := &CertInfo{
-		Cert: cp.Cert,
-		Key:  cp.Key,
-		Snis: []string{s.ServiceCfg.GetSecretSvcSNIS()},
+	_, err = s.checkCert(cp)
+	if err != nil {
+		return err
+	}
+	current, err := s.currentCerts()
+	if err != nil {
+		return err
+	}
+	return s.uploadCert(cp, current)
+}
+
+// uploadCert puts the certificate pair on Kong for the SNIs. The Kong certificates
+// serving only SNIs of the configuration are updated in place, so that the SNIs
+// are never left without a certificate, and the SNIs none of them serves yet are
+// added to the first one. SNIs held by a certificate that also serves other names
+// are moved off it first. A certificate is created when there is none to update.
+func (s *Service) uploadCert(cp *CertPair, current []KongCertificateResponse) error {
+	wanted := map[string]bool{}
+	for _, sni := range s.snis() {
+		wanted[sni] = true
+	}
+	own := []KongCertificateResponse{}
+	held := map[string]bool{}
+	for _, c := range current {
+		others := []string{}
+		for _, sni := range c.Snis {
+			if !wanted[sni] {
+				others = append(others, sni)
+			}
+		}
+		if len(others) == 0 {
+			own = append(own, c)
+			for _, sni := range c.Snis {
+				held[sni] = true
+			}
+			continue
+		}
+		lc.Info(fmt.Sprintf("moving SNIs off cert %s, which keeps %s", c.ID, strings.Join(others, ",")))
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Patch(CertificatesPath + c.ID)
+		err := s.sendCertRequest(sl, &CertInfo{Snis: others})
+		if err != nil {
+			return err
+		}
+	}
+	missing := []string{}
+	for _, sni := range s.snis() {
+		if !held[sni] {
+			missing = append(missing, sni)
+		}
+	}
+
+	if len(own) == 0 {
+		lc.Info("trying to upload cert to proxy server")
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(CertificatesPath)
+		return s.sendCertRequest(sl, &CertInfo{Cert: cp.Cert, Key: cp.Key, Snis: s.snis(), Tags: s.managedTags()})
+	}
+	for i, c := range own {
+		snis := c.Snis
+		if i == 0 {
+			snis = append(append([]string{}, snis...), missing...)
+		}
+		lc.Info(fmt.Sprintf("trying to update cert %s on proxy server", c.ID))
+		sl := sling.New().Base(s.Connect.GetProxyBaseURL()).Patch(CertificatesPath + c.ID)
+		err := s.sendCertRequest(sl, &CertInfo{Cert: cp.Cert, Key: cp.Key, Snis: snis, Tags: s.managedTags()})
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	lc.Info("trying to upload cert to proxy server")
-	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(CertificatesPath).BodyJSON(body).Request()
+func (s *Service) sendCertRequest(sl *sling.Sling, body *CertInfo) error {
+	req, err := sl.BodyJSON(body).Request()
+	if err != nil {
+		return err
+	}
 	resp, err := s.Connect.GetHttpClient().Do(req)
 	if err != nil {
-		lc.Error("failed to upload cert to proxy server with error %s", err.Error())
+		lc.Error(fmt.Sprintf("failed to upload cert to proxy server with error %s", err.Error()))
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
 		lc.Info("successful to add certificate to the reverse proxy")
 		return nil
 	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to add certificate with error %s,%s", resp.Status, string(b))
+	lc.Error(e)
+	return errors.New(e)
+}
+
+// currentCerts returns the Kong certificates serving any of the SNIs.
+func (s *Service) currentCerts() ([]KongCertificateResponse, error) {
+	certs := []KongCertificateResponse{}
+	err := listKongObjects(s.Connect, CertificatesPath, &certs)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, sni := range s.snis() {
+		wanted[sni] = true
+	}
+	current := []KongCertificateResponse{}
+	for _, c := range certs {
+		for _, sni := range c.Snis {
+			if wanted[sni] {
+				current = append(current, c)
+				break
+			}
+		}
+	}
+	return current, nil
+}
+
+// checkCert parses and checks the certificate pair, see checkCertPair, and
+// returns its leaf certificate.
+func (s *Service) checkCert(cp *CertPair) (*x509.Certificate, error) {
+	roots, err := loadCAPool(s.ServiceCfg.GetCACertPath())
+	if err != nil {
+		return nil, err
+	}
+	if roots == nil {
+		lc.Warn("secretservice.cacertpath is not set, the chain of the certificate is not verified")
+	}
+	leaf, err := checkCertPair(cp, roots, s.snis(), time.Now())
+	if err != nil {
+		e := fmt.Sprintf("refusing to upload the certificate on path %s: %s", s.CertCfg.GetCertPath(), err.Error())
+		lc.Error(e)
+		return nil, errors.New(e)
+	}
+	return leaf, nil
+}
 
-	return fmt.Errorf("failed to add certificate with errorcode %d", resp.StatusCode)
+// snis returns the server names the certificate is served for, snis may list
+// several separated by commas.
+func (s *Service) snis() []string {
+	return splitGroupList(s.ServiceCfg.GetSecretSvcSNIS())
 }
 
 func (s *Service) getCertPair() (*CertPair, error) {
@@ -206,103 +400,195 @@ func (s *Service) initKongRoutes(r *KongRoute, name string) error {
 	return errors.New(e)
 }
 
-func (s *Service) initACL(name string, whitelist string) error {
-	aclParams := &KongACLPlugin{
-		Name:      name,
-		WhiteList: whitelist,
-	}
-	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(PluginsPath).BodyForm(aclParams).Request()
+// initServiceACL attaches an acl plugin to a single service. Kong applies it
+// to the service instead of the global acl plugin.
+func (s *Service) initServiceACL(name string, aclParams *KongACLPlugin) error {
+	return s.initScopedACL(ServicesPath+name, name, aclParams)
+}
+
+// initRouteACL attaches an acl plugin to a single route, which takes precedence
+// over the acl of its service.
+func (s *Service) initRouteACL(name string, aclParams *KongACLPlugin) error {
+	return s.initScopedACL(RoutesPath+name, "route "+name, aclParams)
+}
+
+func (s *Service) initScopedACL(path string, name string, aclParams *KongACLPlugin) error {
+	pluginsubpath := path + "/plugins"
+	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(pluginsubpath).BodyForm(aclParams).Request()
 	resp, err := s.Connect.GetHttpClient().Do(req)
 	if err != nil {
-		e := fmt.Sprintf("failed to set up acl")
+		e := fmt.Sprintf("failed to set up acl for %s with error %s", name, err.Error())
 		lc.Error(e)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
-		lc.Info("successful to set up acl")
+		lc.Info(fmt.Sprintf("successful to set up acl for %s", name))
 		return nil
 	}
 
-	e := fmt.Sprintf("failed to set up acl with errorcode %d", resp.StatusCode)
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to set up acl for %s with error %s,%s", name, resp.Status, string(b))
 	lc.Error(e)
 	return errors.New(e)
 }
 
-func (s *Service) initAuthmethod(name string, ttl int) error {
+// initAuthmethod installs the plugins of the authentication method, falling back
+// to the anonymous consumer id when it is set.
+func (s *Service) initAuthmethod(name string, anonymous string) error {
 	lc.Info(fmt.Sprintf("selected auth method as %s.", name))
-	if name == "jwt" {
-		return s.initJWTAuth()
-	} else if name == "oauth2" {
-		return s.initOAuth2(ttl)
+	m, err := lookupAuthMethod(name)
+	if err != nil {
+		return err
 	}
-	return errors.New("unsupported authetication method")
-}
-
-func (s *Service) initJWTAuth() error {
-	jwtParams := &KongJWTPlugin{
-		Name: "jwt",
+	plugins, err := m.plugins(s, anonymous)
+	if err != nil {
+		return err
+	}
+	for _, p := range plugins {
+		err = s.initPlugin(p)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(PluginsPath).BodyForm(jwtParams).Request()
+// initPlugin installs the plugin in its scope unless it already is.
+func (s *Service) initPlugin(p kongPlugin) error {
+	desc := p.description()
+	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(p.path()).BodyForm(p.Params).Request()
 	resp, err := s.Connect.GetHttpClient().Do(req)
 	if err != nil {
-		e := fmt.Sprintf("failed to set up jwt authentication")
+		e := fmt.Sprintf("failed to set up %s with error %s", desc, err.Error())
 		lc.Error(e)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
-		lc.Info("successful to set up jwt authentication")
+		lc.Info(fmt.Sprintf("successful to set up %s", desc))
 		return nil
 	}
 
-	e := fmt.Sprintf("failed to set up jwt authentication with errorcode %d", resp.StatusCode)
+	b, _ := ioutil.ReadAll(resp.Body)
+	e := fmt.Sprintf("failed to set up %s with error %s,%s", desc, resp.Status, string(b))
 	lc.Error(e)
 	return errors.New(e)
 }
 
-func (s *Service) initOAuth2(ttl int) error {
-	oauth2Params := &KongOAuth2Plugin{
-		Name:                    "oauth2",
-		Scope:                   OAuth2Scopes,
-		MandatoryScope:          "true",
-		EnableClientCredentials: "true",
-		EnableGlobalCredentials: "true",
-		TokenTTL:                ttl,
+func (s *Service) managedTags() []string {
+	return managedTags(s.Connect, s.ServiceCfg.GetProxyManagedTag())
+}
+
+func (s *Service) aclPluginParams(name string, whitelist string) *KongACLPlugin {
+	return &KongACLPlugin{
+		Name:      name,
+		WhiteList: whitelist,
+		Tags:      s.managedTags(),
 	}
+}
 
-	req, err := sling.New().Base(s.Connect.GetProxyBaseURL()).Post(PluginsPath).BodyForm(oauth2Params).Request()
-	resp, err := s.Connect.GetHttpClient().Do(req)
-	if err != nil {
-		e := fmt.Sprintf("failed to set up oauth2 authentication with error %s", err.Error())
-		lc.Error(e)
-		return err
+// serviceACLParams returns the acl plugin restricting a service to its allowed
+// groups, or keeping its denied groups out. A service without groups of its own
+// gets the default whitelist, or else an acl keeping the anonymous consumer out.
+// It returns nil when there is no acl to attach. Kong applies the acl of a service
+// in place of the default one, so denied groups are taken off the default whitelist
+// when there is one. Otherwise the anonymous consumer is denied along with them.
+func (s *Service) serviceACLParams(svc service) *KongACLPlugin {
+	whitelist := s.ServiceCfg.GetProxyACLWhiteList()
+	if len(svc.ACLAllow) == 0 && len(svc.ACLDeny) == 0 {
+		if whitelist != "" {
+			return s.aclPluginParams(s.ServiceCfg.GetProxyACLName(), whitelist)
+		}
+		return s.anonymousACLParams()
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict {
-		lc.Info("successful to set up oauth2 authentication")
-		return nil
+	if len(svc.ACLAllow) == 0 && whitelist != "" {
+		allowed := withoutGroups(splitGroupList(whitelist), svc.ACLDeny)
+		return s.aclPluginParams(ACLPlugin, strings.Join(allowed, ","))
 	}
+	deny := svc.ACLDeny
+	if anonymous := s.ServiceCfg.GetProxyAnonymous(); len(deny) > 0 && anonymous != "" {
+		deny = append(append([]string{}, deny...), anonymous)
+	}
+	return &KongACLPlugin{
+		Name:      ACLPlugin,
+		WhiteList: strings.Join(svc.ACLAllow, ","),
+		BlackList: strings.Join(deny, ","),
+		Tags:      s.managedTags(),
+	}
+}
 
-	e := fmt.Sprintf("failed to set up oauth2 authentication with errorcode %d", resp.StatusCode)
-	lc.Error(e)
-	return errors.New(e)
+// withoutGroups returns the groups that are not in deny.
+func withoutGroups(groups []string, deny []string) []string {
+	kept := []string{}
+	for _, g := range groups {
+		denied := false
+		for _, d := range deny {
+			if g == d {
+				denied = true
+				break
+			}
+		}
+		if !denied {
+			kept = append(kept, g)
+		}
+	}
+	return kept
 }
 
-func (s *Service) getSvcIDs(path string) (DataCollect, error) {
-	collection := DataCollect{}
+// jwtPluginParams configures the jwt plugin. Kong refuses a token lacking a claim
+// it verifies, so exp is only verified when token_ttl gives tokens an expiry.
+func (s *Service) jwtPluginParams() *KongJWTPlugin {
+	claims := JWTClaimsToVerify
+	if s.ServiceCfg.GetProxyAuthTTL() > 0 {
+		claims = JWTClaimExpiry + "," + claims
+	}
+	return &KongJWTPlugin{
+		Name:           AuthMethodJWT,
+		ClaimsToVerify: claims,
+		Tags:           s.managedTags(),
+	}
+}
 
-	req, err := sling.New().Get(s.Connect.GetProxyBaseURL()).Path(path).Request()
-	resp, err := s.Connect.GetHttpClient().Do(req)
-	if err != nil {
-		e := fmt.Sprintf("failed to get list of %s with error %s", path, err.Error())
-		return collection, errors.New(e)
+// oauth2PluginParams configures the oauth2 plugin of a service. A service without
+// scopes of its own accepts the tokens of the default scope issued by any such
+// service, while tokens for the scopes of a service are only valid for that service.
+// When refresh_token_ttl is set the password grant is enabled so tokens come
+// with a refresh token. Kong would issue tokens that never expire for a token_ttl
+// of 0, so its default lifetime is kept instead.
+func (s *Service) oauth2PluginParams(svc service) *KongOAuth2Plugin {
+	scopes := OAuth2Scopes
+	globalCredentials := "true"
+	if len(svc.Scopes) > 0 {
+		scopes = strings.Join(svc.Scopes, ",")
+		globalCredentials = "false"
 	}
-	defer resp.Body.Close()
+	refreshTTL := s.ServiceCfg.GetProxyRefreshTTL()
+	enablePasswordGrant := ""
+	if refreshTTL > 0 {
+		enablePasswordGrant = "true"
+	}
+	tokenTTL := s.ServiceCfg.GetProxyAuthTTL()
+	if tokenTTL <= 0 {
+		tokenTTL = OAuth2DefaultTokenTTL
+	}
+	return &KongOAuth2Plugin{
+		Name:                    AuthMethodOAuth2,
+		Scope:                   scopes,
+		MandatoryScope:          "true",
+		EnableClientCredentials: "true",
+		EnableGlobalCredentials: globalCredentials,
+		EnablePasswordGrant:     enablePasswordGrant,
+		TokenTTL:                tokenTTL,
+		RefreshTokenTTL:         refreshTTL,
+		Tags:                    s.managedTags(),
+	}
+}
 
-	json.NewDecoder(resp.Body).Decode(&collection)
-	return collection, nil
+func (s *Service) getSvcIDs(path string) (DataCollect, error) {
+	collection := DataCollect{}
+	err := listKongObjects(s.Connect, path, &collection.Section)
+	return collection, err
 }
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -51,17 +52,49 @@ func (tsc *testServiceCertCfg) GetTokenPath() string {
 	return ""
 }
 
+func (tsc *testServiceCertCfg) GetClientCAPath() string {
+	return ""
+}
+
+func (tsc *testServiceCertCfg) GetSecretSvcAuth() secretauth {
+	return secretauth{}
+}
+
+func (tsc *testServiceCertCfg) GetSecretSvcNamespace() string {
+	return ""
+}
+
+func (tsc *testServiceCertCfg) GetSecretSvcKVVersion() int {
+	return 1
+}
+
+func (tsc *testServiceCertCfg) GetSecretSvcKVMount() string {
+	return DefaultKVMount
+}
+
 type testServiceConfig struct {
 }
 
-func (ts *testServiceConfig) GetProxyAuthMethod() string {
+func (ts *testServiceConfig) GetProxyAuthMethods() []string {
+	return nil
+}
+
+func (ts *testServiceConfig) GetProxyAnonymous() string {
 	return ""
 }
 
+func (ts *testServiceConfig) GetProxyPublicRoutes() []string {
+	return nil
+}
+
 func (ts *testServiceConfig) GetProxyAuthTTL() int {
 	return 0
 }
 
+func (ts *testServiceConfig) GetProxyRefreshTTL() int {
+	return 0
+}
+
 func (ts *testServiceConfig) GetProxyAuthResource() string {
 	return "all"
 }
@@ -74,6 +107,30 @@ func (ts *testServiceConfig) GetProxyACLWhiteList() string {
 	return ""
 }
 
+func (ts *testServiceConfig) GetProxyRateLimit() ratelimit {
+	return ratelimit{}
+}
+
+func (ts *testServiceConfig) GetProxyGroupRateLimits() map[string]ratelimit {
+	return nil
+}
+
+func (ts *testServiceConfig) GetProxyManagedTag() string {
+	return DefaultManagedTag
+}
+
+func (ts *testServiceConfig) GetProxyServerName() string {
+	return "127.0.0.1"
+}
+
+func (ts *testServiceConfig) GetProxyApplicationPortSSL() string {
+	return "8443"
+}
+
+func (ts *testServiceConfig) GetCACertPath() string {
+	return ""
+}
+
 func (ts *testServiceConfig) GetSecretSvcSNIS() string {
 	return ""
 }
@@ -96,7 +153,7 @@ func TestCheckServiceStatus(t *testing.T) {
 	defer ts.Close()
 
 	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
-	err := svc.checkServiceStatus(ts.URL)
+	err := svc.checkServiceStatus(ts.URL, nil)
 	if err != nil {
 		t.Errorf("failed to check service status")
 		t.Errorf(err.Error())
@@ -116,7 +173,7 @@ func TestInitKongService(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	tk := &KongService{"test", "test", "80", "http"}
+	tk := &KongService{Name: "test", Host: "test", Port: "80", Protocol: "http"}
 	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
 	err := svc.initKongService(tk)
 	if err != nil {
@@ -149,25 +206,188 @@ func TestInitKongRoutes(t *testing.T) {
 	}
 }
 
-func TestInitACL(t *testing.T) {
+func TestInitPlugin(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		if r.Method != "POST" {
 			t.Errorf("expected POST request, got %s instead", r.Method)
 		}
 
-		if r.URL.EscapedPath() != "/plugins/" {
-			t.Errorf("expected request to /plugins/, got %s instead", r.URL.EscapedPath())
+		if r.URL.EscapedPath() != "/services/coredata/plugins" {
+			t.Errorf("expected request to /services/coredata/plugins, got %s instead", r.URL.EscapedPath())
 		}
 	}))
 	defer ts.Close()
 
 	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
 
-	err := svc.initACL("test", "testgroup")
+	acl := svc.aclPluginParams("test", "testgroup")
+	err := svc.initPlugin(kongPlugin{Name: acl.Name, Service: "coredata", Params: acl})
 	if err != nil {
 		t.Errorf("failed to initialize acl")
-		t.Errorf(err.Error())
+		t.Error(err.Error())
+	}
+}
+
+func TestInitServiceACL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		if r.Method != "POST" {
+			t.Errorf("expected POST request, got %s instead", r.Method)
+		}
+
+		if r.URL.EscapedPath() != "/services/command/plugins" {
+			t.Errorf("expected request to /services/command/plugins, got %s instead", r.URL.EscapedPath())
+		}
+
+		if r.FormValue("config.whitelist") != "admin,operators" || r.FormValue("config.blacklist") != "" {
+			t.Errorf("expected whitelist admin,operators only, got %s", r.Form.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+
+	if svc.serviceACLParams(service{Name: "metadata"}) != nil {
+		t.Errorf("expected a service without groups to rely on the default acl")
+	}
+	aclParams := svc.serviceACLParams(service{Name: "command", ACLAllow: []string{"admin", "operators"}})
+	err := svc.initServiceACL("command", aclParams)
+	if err != nil {
+		t.Errorf("failed to initialize acl for service")
+		t.Error(err.Error())
+	}
+}
+
+type testWhitelistServiceConfig struct {
+	testServiceConfig
+}
+
+func (ts *testWhitelistServiceConfig) GetProxyACLWhiteList() string {
+	return "admin,operators,guest"
+}
+
+func TestServiceACLDeny(t *testing.T) {
+	svc := Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testWhitelistServiceConfig{}}
+	acl := svc.serviceACLParams(service{Name: "coredata", ACLDeny: []string{"guest"}})
+	if acl.WhiteList != "admin,operators" || acl.BlackList != "" {
+		t.Errorf("expected denied groups to be taken off the default whitelist, got %+v", acl)
+	}
+}
+
+type testTTLServiceConfig struct {
+	testServiceConfig
+	ttl int
+}
+
+func (ts *testTTLServiceConfig) GetProxyAuthTTL() int {
+	return ts.ttl
+}
+
+func TestJWTPluginParams(t *testing.T) {
+	svc := Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testTTLServiceConfig{}}
+	params := svc.jwtPluginParams()
+	if params.ClaimsToVerify != "nbf" {
+		t.Errorf("expected tokens without an expiry not to have exp verified, got %s", params.ClaimsToVerify)
+	}
+
+	svc.ServiceCfg = &testTTLServiceConfig{ttl: 3600}
+	params = svc.jwtPluginParams()
+	if params.ClaimsToVerify != "exp,nbf" {
+		t.Errorf("expected exp and nbf to be verified, got %s", params.ClaimsToVerify)
+	}
+}
+
+func TestOAuth2PluginParams(t *testing.T) {
+	svc := Service{&testServiceRequestor{""}, &testServiceCertCfg{}, &testServiceConfig{}}
+
+	params := svc.oauth2PluginParams(service{Name: "metadata"})
+	if params.Scope != OAuth2Scopes || params.EnableGlobalCredentials != "true" {
+		t.Errorf("expected a service without scopes to accept global tokens of the default scope")
+	}
+	params = svc.oauth2PluginParams(service{Name: "coredata", Scopes: []string{"coredata:read", "coredata:write"}})
+	if params.Scope != "coredata:read,coredata:write" || params.EnableGlobalCredentials != "false" {
+		t.Errorf("expected a service with scopes to only accept its own tokens, got %+v", params)
+	}
+	if params.TokenTTL != OAuth2DefaultTokenTTL {
+		t.Errorf("expected a token_ttl of 0 to keep the default token lifetime, got %d", params.TokenTTL)
+	}
+}
+
+func TestResetProxy(t *testing.T) {
+	deleted := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.URL.EscapedPath() == "/consumers/" {
+			w.Write([]byte(`{"data":[{"id":"1","tags":["edgex-managed"]},{"id":"2"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer ts.Close()
+
+	svc := Service{&testServiceRequestor{ts.URL}, &testServiceCertCfg{}, &testServiceConfig{}}
+	err := svc.ResetProxy(false)
+	if err != nil {
+		t.Errorf("failed to reset proxy")
+		t.Error(err.Error())
+	}
+	if len(deleted) != 1 || deleted[0] != "/consumers/1" {
+		t.Errorf("expected only the managed consumer to be deleted, got %v instead", deleted)
+	}
+
+	deleted = []string{}
+	err = svc.ResetProxy(true)
+	if err != nil {
+		t.Errorf("failed to reset proxy")
+		t.Error(err.Error())
+	}
+	if len(deleted) != 2 {
+		t.Errorf("expected every consumer to be deleted, got %v instead", deleted)
+	}
+}
+
+func TestResetProxyUntagged(t *testing.T) {
+	deleted := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.EscapedPath() == "/":
+			w.Write([]byte(`{"version":"1.0.3"}`))
+		case r.URL.EscapedPath() == "/consumers/":
+			w.Write([]byte(`{"data":[{"id":"1"},{"id":"2"}]}`))
+		default:
+			w.Write([]byte(`{"data":[]}`))
+		}
+	}))
+	defer ts.Close()
+
+	svc := Service{&EdgeXRequestor{ProxyBaseURL: ts.URL, Client: &http.Client{}}, &testServiceCertCfg{}, &testServiceConfig{}}
+	err := svc.CheckProxyServiceStatus()
+	if err != nil {
+		t.Fatalf("failed to check proxy status: %s", err.Error())
+	}
+	err = svc.ResetProxy(false)
+	if err == nil || !strings.Contains(err.Error(), "--all") {
+		t.Errorf("expected reset to be refused on Kong 1.0.3 without --all, got %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v instead", deleted)
+	}
+
+	err = svc.ResetProxy(true)
+	if err != nil {
+		t.Errorf("failed to reset proxy: %s", err.Error())
+	}
+	if len(deleted) != 2 {
+		t.Errorf("expected every consumer to be deleted, got %v instead", deleted)
 	}
 }
 
@@ -181,6 +401,7 @@ func TestGetSvcIDs(t *testing.T) {
 		if r.URL.EscapedPath() != "/test" {
 			t.Errorf("expected request to /test, got %s instead", r.URL.EscapedPath())
 		}
+		w.Write([]byte(`{"data":[]}`))
 	}))
 	defer ts.Close()
 
@@ -0,0 +1,79 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const httpTimeout = 10 * time.Second
+
+// NewTLSConfig returns the TLS configuration shared by the requests to the secret
+// service and to the SSL port of the reverse proxy. Their certificates are verified
+// against the system roots and the CA bundle at caCertPath. skipVerify turns
+// verification off entirely and is only meant for development setups.
+func NewTLSConfig(caCertPath string, skipVerify bool) (*tls.Config, error) {
+	if skipVerify {
+		lc.Warn("TLS VERIFICATION IS TURNED OFF: the certificates of the secret service and the reverse proxy are not checked and their connections can be intercepted")
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if caCertPath == "" {
+		lc.Info("secretservice.cacertpath is not set, verifying TLS against the system roots only")
+		return &tls.Config{RootCAs: pool}, nil
+	}
+	ca, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s with error %s", caCertPath, err.Error())
+	}
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no PEM certificate found in CA bundle %s", caCertPath)
+	}
+	lc.Info(fmt.Sprintf("verifying TLS against the CA bundle %s", caCertPath))
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// NewHTTPClient returns the client the requests to the reverse proxy and the
+// secret service are made with. serverNames maps the host:port of a server whose
+// certificate is issued for another name to that name. The SSL port of the reverse
+// proxy serves the certificate of its SNI, which is usually not its host name.
+func NewHTTPClient(tlsConfig *tls.Config, serverNames map[string]string) *http.Client {
+	tr := &http.Transport{TLSClientConfig: tlsConfig}
+	if len(serverNames) > 0 {
+		dialer := &net.Dialer{Timeout: httpTimeout}
+		tr.DialTLS = func(network string, addr string) (net.Conn, error) {
+			cfg := tlsConfig.Clone()
+			if name, ok := serverNames[addr]; ok {
+				cfg.ServerName = name
+			} else if host, _, err := net.SplitHostPort(addr); err == nil {
+				cfg.ServerName = host
+			}
+			return tls.DialWithDialer(dialer, network, addr, cfg)
+		}
+	}
+	return &http.Client{Timeout: httpTimeout, Transport: tr}
+}
@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	caPath := filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	ioutil.WriteFile(caPath, ca, 0600)
+
+	cfg, err := NewTLSConfig(caPath, false)
+	if err != nil {
+		t.Fatalf("failed to load CA bundle: %s", err.Error())
+	}
+	_, err = NewHTTPClient(cfg, nil).Get(ts.URL)
+	if err != nil {
+		t.Errorf("expected the server to be verified against the CA bundle: %s", err.Error())
+	}
+
+	// the test certificate is issued for example.com besides 127.0.0.1
+	addr := strings.TrimPrefix(ts.URL, "https://")
+	_, err = NewHTTPClient(cfg, map[string]string{addr: "example.com"}).Get(ts.URL)
+	if err != nil {
+		t.Errorf("expected the server to be verified as example.com: %s", err.Error())
+	}
+	_, err = NewHTTPClient(cfg, map[string]string{addr: "edgex-kong"}).Get(ts.URL)
+	if err == nil {
+		t.Errorf("expected a certificate not issued for edgex-kong to be rejected")
+	}
+
+	cfg, _ = NewTLSConfig("", false)
+	_, err = NewHTTPClient(cfg, nil).Get(ts.URL)
+	if err == nil {
+		t.Errorf("expected the server to be rejected without its CA")
+	}
+
+	cfg, _ = NewTLSConfig("", true)
+	_, err = NewHTTPClient(cfg, nil).Get(ts.URL)
+	if err != nil {
+		t.Errorf("expected verification to be skipped: %s", err.Error())
+	}
+
+	_, err = NewTLSConfig(filepath.Join(dir, "missing.pem"), false)
+	if err == nil {
+		t.Errorf("expected a missing CA bundle to be reported")
+	}
+}
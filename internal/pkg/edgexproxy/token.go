@@ -19,12 +19,18 @@ package edgexproxy
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 )
 
 type userTokenPair struct {
-	User  string
-	Token string
+	User         string
+	Token        string
+	RefreshToken string `json:",omitempty"`
+	ExpiresIn    int    `json:",omitempty"`
+	Scope        string `json:",omitempty"`
+	Certificate  string `json:",omitempty"`
+	PrivateKey   string `json:",omitempty"`
 }
 
 type Writer interface {
@@ -36,10 +42,21 @@ type TokenFileWriter struct {
 }
 
 func (tf *TokenFileWriter) Save(u string, t string) error {
+	return tf.SaveToken(u, &KongOauth2Token{AccessToken: t})
+}
+
+// SaveToken saves the access token of user u together with its refresh token and
+// lifetime, or the client certificate and key issued to it.
+func (tf *TokenFileWriter) SaveToken(u string, t *KongOauth2Token) error {
 
 	data := userTokenPair{
-		User:  u,
-		Token: t,
+		User:         u,
+		Token:        t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    t.Expires,
+		Scope:        t.Scope,
+		Certificate:  t.ClientCert,
+		PrivateKey:   t.ClientKey,
 	}
 
 	jdata, err := json.MarshalIndent(data, "", " ")
@@ -50,3 +67,27 @@ func (tf *TokenFileWriter) Save(u string, t string) error {
 	err = ioutil.WriteFile(tf.Filename, jdata, 0600)
 	return err
 }
+
+// LoadToken reads the token saved for user u.
+func (tf *TokenFileWriter) LoadToken(u string) (*KongOauth2Token, error) {
+	data := userTokenPair{}
+	raw, err := ioutil.ReadFile(tf.Filename)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(raw, &data)
+	if err != nil {
+		return nil, err
+	}
+	if data.User != u {
+		return nil, fmt.Errorf("%s holds the token of user %s instead of %s", tf.Filename, data.User, u)
+	}
+	return &KongOauth2Token{
+		AccessToken:  data.Token,
+		RefreshToken: data.RefreshToken,
+		Expires:      data.ExpiresIn,
+		Scope:        data.Scope,
+		ClientCert:   data.Certificate,
+		ClientKey:    data.PrivateKey,
+	}, nil
+}
@@ -17,8 +17,11 @@
 package edgexproxy
 
 import (
+	"errors"
 	"fmt"
 	"github.com/BurntSushi/toml"
+	"sort"
+	"strings"
 )
 
 type tomlConfig struct {
@@ -26,7 +29,9 @@ type tomlConfig struct {
 	KongURL       kongurl
 	KongAuth      kongauth
 	KongACL       KongACLPlugin
+	KongTags      kongtags
 	SecretService secretservice
+	RateLimits    ratelimits `toml:"ratelimits"`
 	EdgexServices map[string]service
 }
 
@@ -39,9 +44,22 @@ type kongurl struct {
 }
 
 type kongauth struct {
-	Name     string
-	TokenTTL int
-	Resource string
+	Name             string
+	TokenTTL         int `toml:"token_ttl"`
+	RefreshTTL       int `toml:"refresh_token_ttl"`
+	Resource         string
+	JWTGroups        bool     `toml:"jwt_groups"`
+	JWTAudience      string   `toml:"jwt_audience"`
+	JWTAlgorithm     string   `toml:"jwt_algorithm"`
+	JWTKeyPath       string   `toml:"jwt_keypath"`
+	OAuth2SecretPath string   `toml:"oauth2_secretpath"`
+	Methods          []string `toml:"methods"`
+	Anonymous        string   `toml:"anonymous"`
+	PublicRoutes     []string `toml:"public_routes"`
+}
+
+type kongtags struct {
+	Managed string
 }
 
 type kongacl struct {
@@ -56,14 +74,58 @@ type secretservice struct {
 	CertPath        string
 	TokenPath       string
 	CACertPath      string
+	ClientCAPath    string
 	SNIS            string
+	Namespace       string
+	KVVersion       int `toml:"kvversion"`
+	KVMount         string
+	Auth            secretauth
+}
+
+// secretauth selects how edgexproxy logs in to the secret service. Method is
+// one of token (the init file at tokenpath), env, approle or kubernetes.
+type secretauth struct {
+	Method       string
+	TokenEnv     string
+	Mount        string
+	RoleID       string
+	SecretIDPath string
+	Role         string
+	JWTPath      string
 }
 
 type service struct {
-	Name     string
-	Host     string
-	Port     string
-	Protocol string
+	Name        string
+	Host        string
+	Port        string
+	Protocol    string
+	ACLAllow    []string  `toml:"aclallow"`
+	ACLDeny     []string  `toml:"acldeny"`
+	Roles       []role    `toml:"roles"`
+	Scopes      []string  `toml:"scopes"`
+	PublicPaths []string  `toml:"publicpaths"`
+	RateLimit   ratelimit `toml:"ratelimit"`
+}
+
+// ratelimits are the rate limit policies of every service and of the members of groups.
+type ratelimits struct {
+	Global ratelimit
+	Groups map[string]ratelimit
+}
+
+// ratelimit caps the number of requests a consumer can send in each window,
+// 0 leaves the window unlimited.
+type ratelimit struct {
+	Second int
+	Minute int
+	Hour   int
+	Month  int
+}
+
+// role grants groups access to a service with the listed HTTP methods.
+type role struct {
+	Groups  []string
+	Methods []string
 }
 
 func LoadTomlConfig(path string) (*tomlConfig, error) {
@@ -72,6 +134,138 @@ func LoadTomlConfig(path string) (*tomlConfig, error) {
 	return &config, err
 }
 
+// Validate checks that the configuration holds everything init and account
+// management need, and reports every problem found at once.
+func (cfg *tomlConfig) Validate() error {
+	problems := []string{}
+	required := map[string]string{
+		"kongurl.server":             cfg.KongURL.Server,
+		"kongurl.adminport":          cfg.KongURL.AdminPort,
+		"kongurl.applicationportssl": cfg.KongURL.ApplicationPortSSL,
+		"secretservice.server":       cfg.SecretService.Server,
+		"secretservice.port":         cfg.SecretService.Port,
+		"secretservice.certpath":     cfg.SecretService.CertPath,
+		"secretservice.snis":         cfg.SecretService.SNIS,
+	}
+	for key, value := range required {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is not set", key))
+		}
+	}
+
+	problems = append(problems, cfg.SecretService.Auth.problems(cfg.SecretService.TokenPath)...)
+	if v := cfg.SecretService.KVVersion; v != 0 && v != 1 && v != 2 {
+		problems = append(problems, fmt.Sprintf("secretservice.kvversion %d is not supported, use 1 or 2", v))
+	}
+
+	methods := map[string]bool{}
+	for _, name := range cfg.GetProxyAuthMethods() {
+		if _, ok := authMethods[name]; !ok {
+			problems = append(problems, fmt.Sprintf("kongauth method %q is not a supported authentication method, use one of %s", name, strings.Join(AuthMethodNames(), ", ")))
+		} else if methods[name] {
+			problems = append(problems, fmt.Sprintf("kongauth method %q is listed twice", name))
+		}
+		methods[name] = true
+	}
+	if len(cfg.KongAuth.Methods) > 0 && cfg.KongAuth.Name != "" && !methods[cfg.KongAuth.Name] {
+		problems = append(problems, fmt.Sprintf("kongauth.name %q is not one of kongauth.methods", cfg.KongAuth.Name))
+	}
+	if len(methods) > 1 && cfg.KongAuth.Anonymous == "" {
+		problems = append(problems, "kongauth.anonymous is required when more than one authentication method is set")
+	}
+	if len(cfg.KongAuth.PublicRoutes) > 0 && cfg.KongAuth.Anonymous == "" {
+		problems = append(problems, "kongauth.anonymous is required for kongauth.public_routes")
+	}
+	for _, p := range cfg.KongAuth.PublicRoutes {
+		if !strings.HasPrefix(p, "/") {
+			problems = append(problems, fmt.Sprintf("kongauth public route %q must start with /", p))
+		}
+	}
+	if methods[AuthMethodMTLSAuth] && cfg.SecretService.ClientCAPath == "" {
+		problems = append(problems, "secretservice.clientcapath is required for mtls-auth authentication")
+	}
+	if methods[AuthMethodOAuth2] && cfg.KongAuth.Resource == "" {
+		problems = append(problems, "kongauth.resource is required for oauth2 authentication")
+	}
+	if methods[AuthMethodOAuth2] && cfg.KongAuth.OAuth2SecretPath == "" {
+		problems = append(problems, "kongauth.oauth2_secretpath is required for oauth2 authentication")
+	}
+	switch cfg.GetProxyJWTAlgorithm() {
+	case JWTAlgorithmHS256:
+	case JWTAlgorithmRS256, JWTAlgorithmES256:
+		if cfg.KongAuth.JWTKeyPath == "" {
+			problems = append(problems, fmt.Sprintf("kongauth.jwt_keypath is required for %s signing", cfg.KongAuth.JWTAlgorithm))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("kongauth.jwt_algorithm %q is not supported", cfg.KongAuth.JWTAlgorithm))
+	}
+	if cfg.KongAuth.TokenTTL < 0 {
+		problems = append(problems, "kongauth.token_ttl can't be negative")
+	}
+	if cfg.KongAuth.RefreshTTL < 0 {
+		problems = append(problems, "kongauth.refresh_token_ttl can't be negative")
+	}
+
+	problems = append(problems, cfg.RateLimits.Global.problems("ratelimits.global")...)
+	for group, limit := range cfg.RateLimits.Groups {
+		problems = append(problems, limit.problems(fmt.Sprintf("ratelimits.groups.%s", group))...)
+	}
+
+	if len(cfg.EdgexServices) == 0 {
+		problems = append(problems, "no edgexservices are configured")
+	}
+	scopes := map[string]string{}
+	for key, svc := range cfg.EdgexServices {
+		for _, scope := range svc.Scopes {
+			if scope == OAuth2Scopes {
+				problems = append(problems, fmt.Sprintf("edgexservices.%s can't declare the default scope %q", key, scope))
+			} else if other, ok := scopes[scope]; ok {
+				problems = append(problems, fmt.Sprintf("scope %q is declared by both edgexservices.%s and edgexservices.%s", scope, other, key))
+			}
+			scopes[scope] = key
+		}
+		if svc.Name == "" || svc.Host == "" || svc.Port == "" {
+			problems = append(problems, fmt.Sprintf("edgexservices.%s needs a name, host and port", key))
+		}
+		if svc.Protocol != "" && svc.Protocol != "http" && svc.Protocol != "https" {
+			problems = append(problems, fmt.Sprintf("edgexservices.%s has unsupported protocol %q", key, svc.Protocol))
+		}
+		if len(svc.ACLAllow) > 0 && len(svc.ACLDeny) > 0 {
+			problems = append(problems, fmt.Sprintf("edgexservices.%s can set either aclallow or acldeny, not both", key))
+		}
+		if whitelist := splitGroupList(cfg.KongACL.WhiteList); len(svc.ACLDeny) > 0 && len(whitelist) > 0 && len(withoutGroups(whitelist, svc.ACLDeny)) == 0 {
+			problems = append(problems, fmt.Sprintf("edgexservices.%s acldeny leaves no group of the kongacl whitelist", key))
+		}
+		for _, p := range svc.PublicPaths {
+			if !strings.HasPrefix(p, "/") {
+				problems = append(problems, fmt.Sprintf("edgexservices.%s public path %q must start with /", key, p))
+			}
+			for _, r := range cfg.KongAuth.PublicRoutes {
+				if r == p {
+					problems = append(problems, fmt.Sprintf("edgexservices.%s public path %q is also a kongauth public route", key, p))
+				}
+			}
+		}
+		problems = append(problems, svc.RateLimit.problems(fmt.Sprintf("edgexservices.%s.ratelimit", key))...)
+		for i, r := range svc.Roles {
+			if len(r.Groups) == 0 || len(r.Methods) == 0 {
+				problems = append(problems, fmt.Sprintf("edgexservices.%s role %d needs groups and methods", key, i+1))
+			}
+			for _, m := range r.Methods {
+				if !roleMethods[strings.ToUpper(m)] {
+					problems = append(problems, fmt.Sprintf("edgexservices.%s role %d has unsupported method %q", key, i+1, m))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 func (cfg *tomlConfig) GetCertPath() string {
 	return cfg.SecretService.CertPath
 }
@@ -80,6 +274,46 @@ func (cfg *tomlConfig) GetTokenPath() string {
 	return cfg.SecretService.TokenPath
 }
 
+// GetCACertPath returns the CA bundle the certificates of the secret service and
+// the SSL port of the reverse proxy are verified against.
+func (cfg *tomlConfig) GetCACertPath() string {
+	return cfg.SecretService.CACertPath
+}
+
+// GetClientCAPath returns the secret service path of the CA that signs the client
+// certificates of consumers.
+func (cfg *tomlConfig) GetClientCAPath() string {
+	return cfg.SecretService.ClientCAPath
+}
+
+// GetSecretSvcAuth returns how to log in to the secret service.
+func (cfg *tomlConfig) GetSecretSvcAuth() secretauth {
+	return cfg.SecretService.Auth
+}
+
+// GetSecretSvcNamespace returns the Vault namespace secrets are read from, empty
+// for the root namespace.
+func (cfg *tomlConfig) GetSecretSvcNamespace() string {
+	return cfg.SecretService.Namespace
+}
+
+// GetSecretSvcKVVersion returns the version of the key/value secrets engine
+// holding the secrets, 1 unless set.
+func (cfg *tomlConfig) GetSecretSvcKVVersion() int {
+	if cfg.SecretService.KVVersion == 0 {
+		return 1
+	}
+	return cfg.SecretService.KVVersion
+}
+
+// GetSecretSvcKVMount returns the path the key/value secrets engine is mounted at.
+func (cfg *tomlConfig) GetSecretSvcKVMount() string {
+	if cfg.SecretService.KVMount == "" {
+		return DefaultKVMount
+	}
+	return cfg.SecretService.KVMount
+}
+
 func (cfg *tomlConfig) GetProxyServerName() string {
 	return cfg.KongURL.Server
 }
@@ -92,10 +326,35 @@ func (cfg *tomlConfig) GetProxyApplicationPortSSL() string {
 	return cfg.KongURL.ApplicationPortSSL
 }
 
+// GetProxyAuthMethod returns the authentication method tokens are issued with
+// unless another one is asked for.
 func (cfg *tomlConfig) GetProxyAuthMethod() string {
+	if cfg.KongAuth.Name == "" && len(cfg.KongAuth.Methods) > 0 {
+		return cfg.KongAuth.Methods[0]
+	}
 	return cfg.KongAuth.Name
 }
 
+// GetProxyAuthMethods returns the authentication methods installed together,
+// only the one of kongauth.name when kongauth.methods is not set.
+func (cfg *tomlConfig) GetProxyAuthMethods() []string {
+	if len(cfg.KongAuth.Methods) > 0 {
+		return cfg.KongAuth.Methods
+	}
+	return []string{cfg.KongAuth.Name}
+}
+
+// GetProxyAnonymous returns the name of the consumer requests no authentication
+// method accepts fall back to, empty when they are rejected.
+func (cfg *tomlConfig) GetProxyAnonymous() string {
+	return cfg.KongAuth.Anonymous
+}
+
+// GetProxyPublicRoutes returns the paths of every service anonymous requests can reach.
+func (cfg *tomlConfig) GetProxyPublicRoutes() []string {
+	return cfg.KongAuth.PublicRoutes
+}
+
 func (cfg *tomlConfig) GetProxyAuthTTL() int {
 	return cfg.KongAuth.TokenTTL
 }
@@ -104,6 +363,42 @@ func (cfg *tomlConfig) GetProxyAuthResource() string {
 	return cfg.KongAuth.Resource
 }
 
+// GetProxyRefreshTTL returns the lifetime of oauth2 refresh tokens in seconds,
+// 0 when no refresh tokens are issued.
+func (cfg *tomlConfig) GetProxyRefreshTTL() int {
+	return cfg.KongAuth.RefreshTTL
+}
+
+// GetProxyJWTGroups reports whether jwt tokens carry the groups of their consumer.
+func (cfg *tomlConfig) GetProxyJWTGroups() bool {
+	return cfg.KongAuth.JWTGroups
+}
+
+// GetProxyJWTAlgorithm returns the algorithm jwt tokens are signed with.
+func (cfg *tomlConfig) GetProxyJWTAlgorithm() string {
+	if cfg.KongAuth.JWTAlgorithm == "" {
+		return JWTAlgorithmHS256
+	}
+	return cfg.KongAuth.JWTAlgorithm
+}
+
+// GetProxyJWTKeyPath returns the secret service path under which the private
+// signing keys of consumers are stored.
+func (cfg *tomlConfig) GetProxyJWTKeyPath() string {
+	return cfg.KongAuth.JWTKeyPath
+}
+
+// GetProxyOAuth2SecretPath returns the secret service path under which the oauth2
+// client secrets of consumers are stored.
+func (cfg *tomlConfig) GetProxyOAuth2SecretPath() string {
+	return cfg.KongAuth.OAuth2SecretPath
+}
+
+// GetProxyJWTAudience returns the aud claim of jwt tokens, empty for none.
+func (cfg *tomlConfig) GetProxyJWTAudience() string {
+	return cfg.KongAuth.JWTAudience
+}
+
 func (cfg *tomlConfig) GetProxyACLName() string {
 	return cfg.KongACL.Name
 }
@@ -112,6 +407,25 @@ func (cfg *tomlConfig) GetProxyACLWhiteList() string {
 	return cfg.KongACL.WhiteList
 }
 
+// GetProxyRateLimit returns the rate limit every service is given unless it
+// sets one of its own.
+func (cfg *tomlConfig) GetProxyRateLimit() ratelimit {
+	return cfg.RateLimits.Global
+}
+
+// GetProxyGroupRateLimits returns the rate limits of the members of each group.
+func (cfg *tomlConfig) GetProxyGroupRateLimits() map[string]ratelimit {
+	return cfg.RateLimits.Groups
+}
+
+// GetProxyManagedTag returns the Kong tag put on every object edgexproxy creates.
+func (cfg *tomlConfig) GetProxyManagedTag() string {
+	if cfg.KongTags.Managed == "" {
+		return DefaultManagedTag
+	}
+	return cfg.KongTags.Managed
+}
+
 func (cfg *tomlConfig) GetSecretSvcName() string {
 	return cfg.SecretService.Server
 }
@@ -17,6 +17,7 @@
 package edgexproxy
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -30,8 +31,80 @@ func TestLoadTomlConfig(t *testing.T) {
 	if config.SecretService.TokenPath != "/test/resp-init.json" {
 		t.Errorf("Failed to get correct value for tokenpath in the toml config file.")
 	}
+	if config.GetProxyManagedTag() != "edgex-managed" {
+		t.Errorf("Failed to get correct value for managed tag in the toml config file.")
+	}
 	if config.EdgexServices["test"].Name != "test" {
 		t.Errorf("Failed to get correct name for test service in the toml config file.")
 	}
+	if len(config.EdgexServices["command"].ACLAllow) != 2 || config.EdgexServices["exportclient"].ACLDeny[0] != "operators" {
+		t.Errorf("Failed to get correct acl groups for services in the toml config file.")
+	}
+	if len(config.EdgexServices["metadata"].Roles) != 2 || config.EdgexServices["metadata"].Roles[1].Groups[0] != "integration" {
+		t.Errorf("Failed to get correct roles for metadata service in the toml config file.")
+	}
+
+}
+
+func TestValidateConfig(t *testing.T) {
+	path := "../../../test/tomltest.toml"
+	config, err := LoadTomlConfig(path)
+	if err != nil {
+		t.Errorf("Failed to parse toml file.")
+		t.Error(err.Error())
+	}
+	err = config.Validate()
+	if err != nil {
+		t.Errorf("Expected the test config file to be valid.")
+		t.Error(err.Error())
+	}
 
+	command := config.EdgexServices["command"]
+	command.ACLDeny = []string{"guest"}
+	config.EdgexServices["command"] = command
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "edgexservices.command") {
+		t.Errorf("Expected a service with both aclallow and acldeny to be reported.")
+	}
+
+	command.ACLAllow = nil
+	command.ACLDeny = []string{"admin"}
+	config.EdgexServices["command"] = command
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "leaves no group") {
+		t.Errorf("Expected a service denying the whole whitelist to be reported.")
+	}
+
+	metadata := config.EdgexServices["metadata"]
+	metadata.Roles = []role{{Groups: []string{"dashboards"}, Methods: []string{"FETCH"}}}
+	config.EdgexServices["metadata"] = metadata
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "FETCH") {
+		t.Errorf("Expected a role with an unsupported method to be reported.")
+	}
+
+	if config.GetProxyGroupRateLimits()["devices"].Hour != 3600 || config.EdgexServices["test"].RateLimit.Minute != 1200 {
+		t.Errorf("Expected the group and service rate limits to be loaded.")
+	}
+	config.RateLimits.Global = ratelimit{Second: 100, Minute: 60}
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ratelimits.global.minute") {
+		t.Errorf("Expected a minute limit lower than the second limit to be reported.")
+	}
+	config.RateLimits.Global = ratelimit{}
+
+	config.KongAuth.Methods = []string{"oauth2", "key-auth"}
+	config.KongAuth.Anonymous = ""
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "kongauth.anonymous") {
+		t.Errorf("Expected several auth methods without an anonymous consumer to be reported.")
+	}
+	config.KongAuth.Methods = nil
+
+	config.KongAuth.Name = "basic"
+	config.SecretService.CertPath = ""
+	err = config.Validate()
+	if err == nil {
+		t.Errorf("Expected an unsupported auth method and a missing certpath to be reported.")
+	}
 }
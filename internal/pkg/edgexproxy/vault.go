@@ -0,0 +1,352 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dghubble/sling"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultSession is a token obtained by logging in to the secret service.
+type vaultSession struct {
+	token     string
+	renewable bool
+	issued    time.Time
+	lease     time.Duration
+}
+
+type vaultAuthResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+type vaultTokenLookup struct {
+	Data struct {
+		TTL       int  `json:"ttl"`
+		Renewable bool `json:"renewable"`
+	} `json:"data"`
+}
+
+func (a secretauth) method() string {
+	if a.Method == "" {
+		return VaultAuthToken
+	}
+	return a.Method
+}
+
+// mount returns the path the auth method is enabled at, which defaults to the
+// name of the method.
+func (a secretauth) mount() string {
+	if a.Mount == "" {
+		return a.method()
+	}
+	return strings.Trim(a.Mount, "/")
+}
+
+func (a secretauth) jwtPath() string {
+	if a.JWTPath == "" {
+		return DefaultKubernetesJWT
+	}
+	return a.JWTPath
+}
+
+func (a secretauth) tokenEnv() string {
+	if a.TokenEnv == "" {
+		return DefaultVaultTokenEnv
+	}
+	return a.TokenEnv
+}
+
+// problems lists what is missing to log in with the auth method.
+func (a secretauth) problems(tokenPath string) []string {
+	problems := []string{}
+	switch a.method() {
+	case VaultAuthToken:
+		if tokenPath == "" {
+			problems = append(problems, "secretservice.tokenpath is not set")
+		}
+	case VaultAuthEnv:
+	case VaultAuthAppRole:
+		if a.RoleID == "" || a.SecretIDPath == "" {
+			problems = append(problems, "secretservice.auth.roleid and secretservice.auth.secretidpath are required for approle login")
+		}
+	case VaultAuthKubernetes:
+		if a.Role == "" {
+			problems = append(problems, "secretservice.auth.role is required for kubernetes login")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("secretservice.auth.method %q is not supported, use token, env, approle or kubernetes", a.Method))
+	}
+	return problems
+}
+
+// getToken returns the token requests to the secret service are made with.
+func (cs *Certs) getToken() (string, error) {
+	a := cs.Cfg.GetSecretSvcAuth()
+	switch a.method() {
+	case VaultAuthToken:
+		return cs.getSecret(cs.Cfg.GetTokenPath())
+	case VaultAuthEnv:
+		t := os.Getenv(a.tokenEnv())
+		if t == "" {
+			return "", fmt.Errorf("environment variable %s holds no secret service token", a.tokenEnv())
+		}
+		return t, nil
+	}
+	return cs.loginToken(a, false)
+}
+
+// vault returns a request builder for the secret service authenticated with
+// the current token.
+func (cs *Certs) vault() (*sling.Sling, error) {
+	t, err := cs.getToken()
+	if err != nil {
+		return nil, err
+	}
+	return cs.vaultSling(t), nil
+}
+
+func (cs *Certs) vaultSling(t string) *sling.Sling {
+	sl := sling.New().Base(cs.Connect.GetSecretSvcBaseURL())
+	if t != "" {
+		sl.Set(VaultToken, t)
+	}
+	if ns := cs.Cfg.GetSecretSvcNamespace(); ns != "" {
+		sl.Set(VaultNamespace, ns)
+	}
+	return sl
+}
+
+// RenewToken extends the lease of the secret service token. Long running modes
+// call it periodically so their token outlives its first lease, one-shot commands
+// have no need to. A login that can't be renewed is replaced by a new one, while
+// a token that comes without a lease is left as it is.
+func (cs *Certs) RenewToken() error {
+	a := cs.Cfg.GetSecretSvcAuth()
+	if a.method() == VaultAuthAppRole || a.method() == VaultAuthKubernetes {
+		_, err := cs.loginToken(a, true)
+		return err
+	}
+
+	t, err := cs.getToken()
+	if err != nil {
+		return err
+	}
+	lookup := vaultTokenLookup{}
+	err = cs.sendVaultRequest(cs.vaultSling(t).Get("v1/auth/token/lookup-self"), "look up secret service token", &lookup)
+	if err != nil {
+		return err
+	}
+	if !lookup.Data.Renewable || lookup.Data.TTL == 0 {
+		lc.Info("secret service token has no lease to renew")
+		return nil
+	}
+	_, err = cs.renewSelf(t)
+	return err
+}
+
+func (cs *Certs) sessionKey(a secretauth) string {
+	return strings.Join([]string{cs.Connect.GetSecretSvcBaseURL(), cs.Cfg.GetSecretSvcNamespace(), a.method(), a.mount(), a.RoleID, a.Role}, "|")
+}
+
+// loginToken returns the token of the current login. The login is kept by the
+// Requestor, so that the Certs built for each request share it until its lease
+// runs out, while a Requestor that keeps no state logs in on every call. The token
+// is renewed once half of its lease has passed, or right away when renew is set,
+// and a new login is made when it has expired or can't be renewed.
+func (cs *Certs) loginToken(a secretauth, renew bool) (string, error) {
+	rs := stateOf(cs.Connect)
+	if rs == nil {
+		rs = &requestorState{}
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if rs.vault == nil {
+		rs.vault = map[string]*vaultSession{}
+	}
+
+	key := cs.sessionKey(a)
+	session := rs.vault[key]
+	now := time.Now()
+	if session != nil && !renew && (session.lease == 0 || now.Before(session.issued.Add(session.lease/2))) {
+		return session.token, nil
+	}
+	if session != nil && session.renewable && now.Before(session.issued.Add(session.lease)) {
+		renewed, err := cs.renewSelf(session.token)
+		if err == nil {
+			rs.vault[key] = renewed
+			return renewed.token, nil
+		}
+		lc.Info(fmt.Sprintf("logging in to the secret service again as renewing failed with error %s", err.Error()))
+	}
+
+	session, err := cs.login(a)
+	if err != nil {
+		return "", err
+	}
+	rs.vault[key] = session
+	return session.token, nil
+}
+
+// login logs in with the approle or kubernetes auth method.
+func (cs *Certs) login(a secretauth) (*vaultSession, error) {
+	body := map[string]string{}
+	switch a.method() {
+	case VaultAuthAppRole:
+		secretID, err := readCredentialFile(a.SecretIDPath)
+		if err != nil {
+			return nil, err
+		}
+		body["role_id"] = a.RoleID
+		body["secret_id"] = secretID
+	case VaultAuthKubernetes:
+		jwt, err := readCredentialFile(a.jwtPath())
+		if err != nil {
+			return nil, err
+		}
+		body["role"] = a.Role
+		body["jwt"] = jwt
+	default:
+		return nil, fmt.Errorf("secret service auth method %s can't log in", a.method())
+	}
+
+	path := fmt.Sprintf("v1/auth/%s/login", a.mount())
+	session, err := cs.sendAuthRequest(cs.vaultSling("").Post(path).BodyJSON(body), fmt.Sprintf("log in to the secret service with %s", a.method()))
+	if err != nil {
+		return nil, err
+	}
+	lc.Info(fmt.Sprintf("successful to log in to the secret service with %s", a.method()))
+	return session, nil
+}
+
+func (cs *Certs) renewSelf(t string) (*vaultSession, error) {
+	session, err := cs.sendAuthRequest(cs.vaultSling(t).Post("v1/auth/token/renew-self"), "renew secret service token")
+	if err != nil {
+		return nil, err
+	}
+	lc.Info(fmt.Sprintf("successful to renew secret service token for %s", session.lease))
+	return session, nil
+}
+
+func (cs *Certs) sendAuthRequest(sl *sling.Sling, desc string) (*vaultSession, error) {
+	auth := vaultAuthResponse{}
+	err := cs.sendVaultRequest(sl, desc, &auth)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("failed to %s, the secret service returned no token", desc)
+	}
+	return &vaultSession{
+		token:     auth.Auth.ClientToken,
+		renewable: auth.Auth.Renewable,
+		issued:    time.Now(),
+		lease:     time.Duration(auth.Auth.LeaseDuration) * time.Second,
+	}, nil
+}
+
+func (cs *Certs) sendVaultRequest(sl *sling.Sling, desc string, v interface{}) error {
+	req, err := sl.Request()
+	if err != nil {
+		return err
+	}
+	resp, err := cs.Connect.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s with error %s", desc, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		e := fmt.Sprintf("failed to %s with error %s,%s", desc, resp.Status, string(b))
+		lc.Error(e)
+		return errors.New(e)
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("failed to decode response to %s with error %s", desc, err.Error())
+	}
+	return nil
+}
+
+// readCredentialFile reads a secret id or service account token from filename.
+func readCredentialFile(filename string) (string, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret service credential from %s with error %s", filename, err.Error())
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// kvPath maps the path of a secret to the API path of the key/value secrets
+// engine. With version 2 of the engine, secrets below its mount are read and
+// written under data/ and deleted with every version under metadata/.
+func (cs *Certs) kvPath(path string, kind string) string {
+	if cs.Cfg.GetSecretSvcKVVersion() != 2 {
+		return path
+	}
+	mount := "v1/" + strings.Trim(cs.Cfg.GetSecretSvcKVMount(), "/") + "/"
+	rel := strings.TrimPrefix(path, "/")
+	if !strings.HasPrefix(rel, mount) {
+		return path
+	}
+	rest := strings.TrimPrefix(rel, mount)
+	rest = strings.TrimPrefix(strings.TrimPrefix(rest, "data/"), "metadata/")
+	return mount + kind + "/" + rest
+}
+
+// kvBody wraps the data of a secret the way the key/value secrets engine expects it.
+func (cs *Certs) kvBody(data interface{}) interface{} {
+	if cs.Cfg.GetSecretSvcKVVersion() != 2 {
+		return data
+	}
+	return map[string]interface{}{"data": data}
+}
+
+// decodeSecret decodes the data of a secret into v. Version 2 of the key/value
+// engine nests the data of the secret next to its metadata, version 1 returns it as is.
+func decodeSecret(r io.Reader, v interface{}) error {
+	secret := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	err := json.NewDecoder(r).Decode(&secret)
+	if err != nil {
+		return err
+	}
+	if len(secret.Data) == 0 {
+		return nil
+	}
+	versioned := struct {
+		Data     json.RawMessage `json:"data"`
+		Metadata json.RawMessage `json:"metadata"`
+	}{}
+	if json.Unmarshal(secret.Data, &versioned) == nil && len(versioned.Data) > 0 && len(versioned.Metadata) > 0 {
+		return json.Unmarshal(versioned.Data, v)
+	}
+	return json.Unmarshal(secret.Data, v)
+}
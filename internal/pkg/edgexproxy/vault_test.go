@@ -0,0 +1,165 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testAppRoleCertCfg struct {
+	testCertCfg
+	secretIDPath string
+}
+
+func (tc *testAppRoleCertCfg) GetSecretSvcAuth() secretauth {
+	return secretauth{Method: VaultAuthAppRole, RoleID: "edgex-proxy", SecretIDPath: tc.secretIDPath}
+}
+
+func (tc *testAppRoleCertCfg) GetSecretSvcNamespace() string {
+	return "edgex"
+}
+
+func (tc *testAppRoleCertCfg) GetSecretSvcKVVersion() int {
+	return 2
+}
+
+func TestAppRoleLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approle")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	secretIDPath := filepath.Join(dir, "secret-id")
+	ioutil.WriteFile(secretIDPath, []byte("secret-id\n"), 0600)
+
+	logins := 0
+	renewals := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(VaultNamespace) != "edgex" {
+			t.Errorf("expected requests to the edgex namespace, got %q", r.Header.Get(VaultNamespace))
+		}
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "POST /v1/auth/approle/login":
+			logins++
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "edgex-proxy" || body["secret_id"] != "secret-id" {
+				t.Errorf("expected the role id and secret id, got %v", body)
+			}
+			w.Write([]byte(`{"auth":{"client_token":"s.login","lease_duration":3600,"renewable":true}}`))
+		case "POST /v1/auth/token/renew-self":
+			renewals++
+			w.Write([]byte(`{"auth":{"client_token":"s.login","lease_duration":3600,"renewable":true}}`))
+		case "GET /v1/secret/data/edgex/test":
+			if r.Header.Get(VaultToken) != "s.login" {
+				t.Errorf("expected the login token, got %q", r.Header.Get(VaultToken))
+			}
+			w.Write([]byte(`{"data":{"data":{"cert":"test-cert","key":"test-key"},"metadata":{"version":2}}}`))
+		default:
+			t.Errorf("unexpected %s request to %s", r.Method, r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+
+	connect := &EdgeXRequestor{SecretSvcBaseURL: ts.URL, Client: &http.Client{}}
+	for i := 0; i < 2; i++ {
+		cs := Certs{connect, &testAppRoleCertCfg{testCertCfg{}, secretIDPath}}
+		cp := CertPair{}
+		found, err := cs.readSecret("v1/secret/edgex/test", &cp)
+		if err != nil || !found {
+			t.Fatalf("failed to read secret with approle login: %v", err)
+		}
+		if cp.Cert != "test-cert" || cp.Key != "test-key" {
+			t.Errorf("expected the data of the versioned secret, got %+v", cp)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected a single login to be shared, got %d", logins)
+	}
+
+	cs := Certs{connect, &testAppRoleCertCfg{testCertCfg{}, secretIDPath}}
+	err = cs.RenewToken()
+	if err != nil || renewals != 1 || logins != 1 {
+		t.Errorf("expected the login to be renewed, got %d renewals, %d logins and error %v", renewals, logins, err)
+	}
+
+	cs = Certs{&EdgeXRequestor{SecretSvcBaseURL: ts.URL, Client: &http.Client{}}, &testAppRoleCertCfg{testCertCfg{}, secretIDPath}}
+	_, err = cs.readSecret("v1/secret/edgex/test", &CertPair{})
+	if err != nil || logins != 2 {
+		t.Errorf("expected another requestor to log in on its own, got %d logins and error %v", logins, err)
+	}
+}
+
+func TestKVPath(t *testing.T) {
+	cs := Certs{&testRequestor{}, &testAppRoleCertCfg{}}
+	paths := map[string]string{
+		"v1/secret/edgex/pki/tls/edgex-kong":      "v1/secret/data/edgex/pki/tls/edgex-kong",
+		"v1/secret/data/edgex/pki/tls/edgex-kong": "v1/secret/data/edgex/pki/tls/edgex-kong",
+		"v1/pki/issue/edgex":                      "v1/pki/issue/edgex",
+	}
+	for path, want := range paths {
+		if got := cs.kvPath(path, "data"); got != want {
+			t.Errorf("expected %s to map to %s, got %s", path, want, got)
+		}
+	}
+	if got := cs.kvPath("v1/secret/edgex/jwt/testuser", "metadata"); got != "v1/secret/metadata/edgex/jwt/testuser" {
+		t.Errorf("expected secrets to be deleted through their metadata, got %s", got)
+	}
+
+	cs = Certs{&testRequestor{}, &testCertCfg{}}
+	if got := cs.kvPath("v1/secret/edgex/test", "data"); got != "v1/secret/edgex/test" {
+		t.Errorf("expected paths of version 1 to be kept, got %s", got)
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	for _, body := range []string{
+		`{"data":{"cert":"test-cert","key":"test-key"}}`,
+		`{"data":{"data":{"cert":"test-cert","key":"test-key"},"metadata":{"version":1}}}`,
+	} {
+		cp := CertPair{}
+		err := decodeSecret(strings.NewReader(body), &cp)
+		if err != nil || cp.Cert != "test-cert" || cp.Key != "test-key" {
+			t.Errorf("failed to decode %s: %+v %v", body, cp, err)
+		}
+	}
+}
+
+type testEnvCertCfg struct {
+	testCertCfg
+}
+
+func (tc *testEnvCertCfg) GetSecretSvcAuth() secretauth {
+	return secretauth{Method: VaultAuthEnv, TokenEnv: "EDGEX_TEST_VAULT_TOKEN"}
+}
+
+func TestEnvToken(t *testing.T) {
+	os.Setenv("EDGEX_TEST_VAULT_TOKEN", "s.env")
+	defer os.Unsetenv("EDGEX_TEST_VAULT_TOKEN")
+	cs := Certs{&testRequestor{}, &testEnvCertCfg{}}
+	token, err := cs.getToken()
+	if err != nil || token != "s.env" {
+		t.Errorf("expected the token of the environment variable, got %q %v", token, err)
+	}
+}
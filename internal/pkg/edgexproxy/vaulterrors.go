@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 1.0.0
+ *******************************************************************************/
+package edgexproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Vault answers 429 from a standby node and 473 from a performance standby
+// node asked for something only the active node can do.
+const (
+	vaultStatusStandby            = 429
+	vaultStatusPerformanceStandby = 473
+)
+
+// vaultRetries bounds the attempts made while the secret service is sealed or
+// on standby, waiting vaultRetryDelay longer before each new attempt.
+var (
+	vaultRetries    = 5
+	vaultRetryDelay = 2 * time.Second
+)
+
+// VaultError is a request to Op (read, write or delete) the secret at Path that
+// the secret service answered with Status, along with the messages of its errors array.
+type VaultError struct {
+	Op     string
+	Path   string
+	Status int
+	Errors []string
+}
+
+func (e *VaultError) Error() string {
+	return fmt.Sprintf("failed to %s secret on path %s with error %d %s%s", e.Op, e.Path, e.Status, http.StatusText(e.Status), e.detail())
+}
+
+func (e *VaultError) detail() string {
+	if len(e.Errors) == 0 {
+		return ""
+	}
+	return "," + strings.Join(e.Errors, "; ")
+}
+
+// VaultForbiddenError means the token is not allowed to access the secret, or has expired.
+type VaultForbiddenError struct {
+	*VaultError
+}
+
+func (e *VaultForbiddenError) Error() string {
+	return fmt.Sprintf("permission denied to %s secret on path %s with error %d%s, check the policy of the secret service token", e.Op, e.Path, e.Status, e.detail())
+}
+
+// VaultNotFoundError means there is no secret at the path.
+type VaultNotFoundError struct {
+	*VaultError
+}
+
+func (e *VaultNotFoundError) Error() string {
+	return fmt.Sprintf("no secret found on path %s with error %d%s", e.Path, e.Status, e.detail())
+}
+
+// VaultSealedError means the secret service is sealed or the node asked is on
+// standby, so the request may succeed later.
+type VaultSealedError struct {
+	*VaultError
+}
+
+func (e *VaultSealedError) Error() string {
+	return fmt.Sprintf("secret service is sealed or on standby, failed to %s secret on path %s with error %d%s", e.Op, e.Path, e.Status, e.detail())
+}
+
+// newVaultError builds the typed error matching the status of the response to
+// the request to op the secret at path.
+func newVaultError(op string, path string, resp *http.Response) error {
+	e := &VaultError{Op: op, Path: path, Status: resp.StatusCode}
+	b, _ := ioutil.ReadAll(resp.Body)
+	body := struct {
+		Errors []string `json:"errors"`
+	}{}
+	if json.Unmarshal(b, &body) == nil {
+		e.Errors = body.Errors
+	} else if s := strings.TrimSpace(string(b)); s != "" {
+		e.Errors = []string{s}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		return &VaultForbiddenError{e}
+	case http.StatusNotFound:
+		return &VaultNotFoundError{e}
+	case http.StatusServiceUnavailable, vaultStatusStandby, vaultStatusPerformanceStandby:
+		return &VaultSealedError{e}
+	}
+	return e
+}
+
+// withVaultRetries runs call, which does op on the secret at path, until it
+// succeeds, fails for another reason than a sealed or standby secret service, or
+// runs out of attempts.
+func withVaultRetries(op string, path string, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if _, sealed := err.(*VaultSealedError); !sealed || attempt >= vaultRetries {
+			return err
+		}
+		delay := time.Duration(attempt) * vaultRetryDelay
+		lc.Info(fmt.Sprintf("retrying to %s secret on path %s in %s, attempt %d of %d failed: %s", op, path, delay, attempt, vaultRetries, err.Error()))
+		time.Sleep(delay)
+	}
+}